@@ -7,7 +7,7 @@ const [pipeAddressOut, pipeOut, pipeTypeOut] = process.env.out.split(',');
 const inRoutingKey = process.env.inRoutingKey || '#';
 const outRoutingKey = process.env.outRoutingKey || '';
 
-amqp.connect(pipeAddressIn, function(error0, connection) {
+amqp.connect(connectionUrl(pipeAddressIn, 'in'), function(error0, connection) {
   if (error0) {
     throw error0;
   }
@@ -17,8 +17,10 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       throw error1;
     }
 
+    applyPrefetch(channel, 'in');
+
     if (pipeTypeIn === 'queue') {
-      channel.assertQueue(pipeIn);
+      channel.assertQueue(pipeIn, queueOptions('in'));
       console.log("Waiting for messages in queue %s.", pipeIn);
 
       channel.consume(pipeIn, function(msg) {
@@ -34,7 +36,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       });
 
     } else if (pipeTypeIn === 'topic') {
-      channel.assertExchange(pipeIn, 'topic');
+      channel.assertExchange(pipeIn, exchangeType('in'));
       console.log("Waiting for messages on topic exchange %s with routing key %s.", pipeIn, inRoutingKey);
 
       //assert temporary queue
@@ -43,7 +45,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
           throw error2;
         }
 
-        channel.bindQueue(q.queue, pipeIn, inRoutingKey);
+        channel.bindQueue(q.queue, pipeIn, inRoutingKey, bindArguments('in'));
         channel.consume(q.queue, function(msg) {
           if (msg !== null) {
             const message = JSON.parse(msg.content.toString());
@@ -65,12 +67,12 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
 
 function forwardMessage(channel, pipeOut, pipeTypeOut, routingKey, message) {
   if (pipeTypeOut === 'queue') {
-    channel.assertQueue(pipeOut);
+    channel.assertQueue(pipeOut, queueOptions('out'));
     channel.sendToQueue(pipeOut, Buffer.from(JSON.stringify(message)));
     console.log("Forwarded message to queue %s", pipeOut);
 
   } else if (pipeTypeOut === 'topic') {
-    channel.assertExchange(pipeOut, 'topic');
+    channel.assertExchange(pipeOut, exchangeType('out'));
     channel.publish(pipeOut, routingKey, Buffer.from(JSON.stringify(message)));
     console.log("Forwarded message to topic exchange %s with routing key %s", pipeOut, routingKey);
 
@@ -78,3 +80,47 @@ function forwardMessage(channel, pipeOut, pipeTypeOut, routingKey, message) {
     console.error(`Unknown pipe type for output: ${pipeTypeOut}`);
   }
 }
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
@@ -1,49 +1,116 @@
 const amqp = require('amqplib/callback_api');
+const mqtt = require('mqtt');
 const process = require('process');
 
 const [pipeAddress, pipe, pipeType] = process.env.in.split(',');
 const inRoutingKey = process.env.inRoutingKey || '#';
 
-amqp.connect(pipeAddress, function(error0, connection) {
-  if (error0) {
-    throw error0;
-  }
+//pipes with the mqtt protocol are read with an MQTT client, the name of the pipe is the MQTT topic
+if (pipeAddress.startsWith('mqtt://')) {
+  const client = mqtt.connect(pipeAddress);
 
-  connection.createChannel(function(error1, channel) {
-    if (error1) {
-      throw error1;
+  client.on('connect', function() {
+    client.subscribe(pipe, { qos: 1 }, function(error) {
+      if (error) {
+        throw error;
+      }
+      console.log("Waiting for messages on MQTT topic %s.", pipe);
+    });
+  });
+  client.on('message', function(topic, message) {
+    console.log("Received: %s", message.toString());
+  });
+  client.on('error', function(error) {
+    throw error;
+  });
+} else {
+  amqp.connect(connectionUrl(pipeAddress, 'in'), function(error0, connection) {
+    if (error0) {
+      throw error0;
     }
 
-    if (pipeType === 'queue') {
-      channel.assertQueue(pipe);
-      console.log("Waiting for messages in queue %s.", pipe);
-
-      channel.consume(pipe, function(msg) {
-        if (msg !== null) {
-          console.log("Received: %s", msg.content.toString());
-          channel.ack(msg);
-        }
-      });
-    } else if (pipeType === 'topic') {
-      channel.assertExchange(pipe, 'topic');
-      console.log("Waiting for messages on topic %s with routing key %s.", pipe, inRoutingKey);
-
-      //temp queue bound to this exchange
-      channel.assertQueue('', { exclusive: true }, function(error2, q) {
-        if (error2) {
-          throw error2;
-        }
-
-        channel.bindQueue(q.queue, pipe, inRoutingKey);
-        channel.consume(q.queue, function(msg) {
+    connection.createChannel(function(error1, channel) {
+      if (error1) {
+        throw error1;
+      }
+
+      applyPrefetch(channel, 'in');
+
+      if (pipeType === 'queue') {
+        channel.assertQueue(pipe, queueOptions('in'));
+        console.log("Waiting for messages in queue %s.", pipe);
+
+        channel.consume(pipe, function(msg) {
           if (msg !== null) {
             console.log("Received: %s", msg.content.toString());
             channel.ack(msg);
           }
         });
-      });
-    } else {
-      console.error(`Unknown pipe type: ${pipeType}`);
-    }
+      } else if (pipeType === 'topic') {
+        channel.assertExchange(pipe, exchangeType('in'));
+        console.log("Waiting for messages on topic %s with routing key %s.", pipe, inRoutingKey);
+
+        //temp queue bound to this exchange
+        channel.assertQueue('', { exclusive: true }, function(error2, q) {
+          if (error2) {
+            throw error2;
+          }
+
+          channel.bindQueue(q.queue, pipe, inRoutingKey, bindArguments('in'));
+          channel.consume(q.queue, function(msg) {
+            if (msg !== null) {
+              console.log("Received: %s", msg.content.toString());
+              channel.ack(msg);
+            }
+          });
+        });
+      } else {
+        console.error(`Unknown pipe type: ${pipeType}`);
+      }
+    });
   });
-});
+}
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
@@ -1,4 +1,5 @@
 const amqp = require('amqplib/callback_api');
+const mqtt = require('mqtt');
 const process = require('process');
 
 const interval = parseInt(process.env.interval, 10);
@@ -16,56 +17,98 @@ try {
   process.exit(1);
 }
 
-amqp.connect(pipeAddress, function(error0, connection) {
-  if (error0) {
-    throw error0;
-  }
+//pipes with the mqtt protocol are written with an MQTT client, the name of the pipe is the MQTT topic
+if (pipeAddress.startsWith('mqtt://')) {
+  const client = mqtt.connect(pipeAddress);
+  client.on('error', function(error) {
+    throw error;
+  });
+
+  //the client queues messages until it is connected
+  const sendMessage = () => {
+    const cloudEventMessage = createCloudEvent();
 
-  connection.createChannel(function(error1, channel) {
-    if (error1) {
-      throw error1;
+    client.publish(pipe, JSON.stringify(cloudEventMessage), { qos: 1 });
+    console.log("Sent to MQTT topic %s: %s", pipe, JSON.stringify(cloudEventMessage));
+  };
+
+  setInterval(sendMessage, interval);
+} else {
+  amqp.connect(connectionUrl(pipeAddress, 'out'), function(error0, connection) {
+    if (error0) {
+      throw error0;
     }
 
-    if (pipeType === 'queue') {
-      channel.assertQueue(pipe);
-
-      const sendMessage = () => {
-        const cloudEventMessage = {
-          specversion: '1.0',
-          id: `id-${Math.random()}`,
-          source: source,
-          type: type,
-          time: new Date().toISOString(),
-          data: messageData
-        };
+    connection.createChannel(function(error1, channel) {
+      if (error1) {
+        throw error1;
+      }
+
+      if (pipeType === 'queue') {
+        channel.assertQueue(pipe, queueOptions('out'));
 
-        channel.sendToQueue(pipe, Buffer.from(JSON.stringify(cloudEventMessage)));
-        console.log("Sent to queue %s: %s", pipe, JSON.stringify(cloudEventMessage));
-      };
-
-      setInterval(sendMessage, interval);
-    } else if (pipeType === 'topic') {
-      channel.assertExchange(pipe, 'topic');
-
-      const sendMessage = () => {
-        // creates  new CloudEvent message
-        const cloudEventMessage = {
-          specversion: '1.0',
-          id: `id-${Math.random()}`,
-          source: source,
-          type: type,
-          time: new Date().toISOString(),
-          data: messageData
+        const sendMessage = () => {
+          const cloudEventMessage = createCloudEvent();
+
+          channel.sendToQueue(pipe, Buffer.from(JSON.stringify(cloudEventMessage)));
+          console.log("Sent to queue %s: %s", pipe, JSON.stringify(cloudEventMessage));
         };
 
-        channel.publish(pipe, outRoutingKey, Buffer.from(JSON.stringify(cloudEventMessage)));
-        console.log("Sent to exchange %s with routing key %s: %s", pipe, outRoutingKey, JSON.stringify(cloudEventMessage));
-      };
+        setInterval(sendMessage, interval);
+      } else if (pipeType === 'topic') {
+        channel.assertExchange(pipe, exchangeType('out'));
 
-      setInterval(sendMessage, interval);
-    } else {
-      console.error(`Unknown pipe type: ${pipeType}`);
-      process.exit(1);
-    }
+        const sendMessage = () => {
+          const cloudEventMessage = createCloudEvent();
+
+          channel.publish(pipe, outRoutingKey, Buffer.from(JSON.stringify(cloudEventMessage)));
+          console.log("Sent to exchange %s with routing key %s: %s", pipe, outRoutingKey, JSON.stringify(cloudEventMessage));
+        };
+
+        setInterval(sendMessage, interval);
+      } else {
+        console.error(`Unknown pipe type: ${pipeType}`);
+        process.exit(1);
+      }
+    });
   });
-});
+}
+
+//creates a new CloudEvent message with the configured data
+function createCloudEvent() {
+  return {
+    specversion: '1.0',
+    id: `id-${Math.random()}`,
+    source: source,
+    type: type,
+    time: new Date().toISOString(),
+    data: messageData
+  };
+}
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
@@ -12,7 +12,7 @@ const type = process.env.eventType;
 
 let messageBuffer = [];
 
-amqp.connect(pipeAddressIn, function(error0, connection) {
+amqp.connect(connectionUrl(pipeAddressIn, 'in'), function(error0, connection) {
   if (error0) {
     throw error0;
   }
@@ -22,8 +22,10 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       throw error1;
     }
 
+    applyPrefetch(channel, 'in');
+
     if (pipeTypeIn === 'queue') {
-      channel.assertQueue(pipeIn);
+      channel.assertQueue(pipeIn, queueOptions('in'));
       console.log("Waiting for messages in queue %s.", pipeIn);
 
       channel.consume(pipeIn, function(msg) {
@@ -33,7 +35,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       });
 
     } else if (pipeTypeIn === 'topic') {
-      channel.assertExchange(pipeIn, 'topic');
+      channel.assertExchange(pipeIn, exchangeType('in'));
       console.log("Waiting for messages on topic exchange %s with routing key %s.", pipeIn, inRoutingKey);
 
       // temp queue for consuming from topic
@@ -42,7 +44,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
           throw error2;
         }
 
-        channel.bindQueue(q.queue, pipeIn, inRoutingKey);
+        channel.bindQueue(q.queue, pipeIn, inRoutingKey, bindArguments('in'));
         channel.consume(q.queue, function(msg) {
           handleIncomingMessage(channel, msg);
         }, {
@@ -94,12 +96,12 @@ function handleIncomingMessage(channel, msg) {
     };
 
     if (pipeTypeOut === 'queue') {
-      channel.assertQueue(pipeOut);
+      channel.assertQueue(pipeOut, queueOptions('out'));
       channel.sendToQueue(pipeOut, Buffer.from(JSON.stringify(cloudEventMessage)));
       console.log(`Sent aggregated message to queue %s: %s`, pipeOut, JSON.stringify(cloudEventMessage));
 
     } else if (pipeTypeOut === 'topic') {
-      channel.assertExchange(pipeOut, 'topic');
+      channel.assertExchange(pipeOut, exchangeType('out'));
       channel.publish(pipeOut, outRoutingKey, Buffer.from(JSON.stringify(cloudEventMessage)));
       console.log(`Sent aggregated message to topic exchange %s with routing key %s: %s`, pipeOut, outRoutingKey, JSON.stringify(cloudEventMessage));
 
@@ -116,3 +118,47 @@ function getFieldValue(obj, fieldPath) {
   const adjustedPath = fieldPath.replace(/^message\./, '');
   return adjustedPath.split('.').reduce((o, key) => (o && o[key] !== undefined) ? o[key] : undefined, obj);
 }
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
@@ -15,7 +15,7 @@ const mode = process.env.mode;
 const criteriaPath = '/etc/config/criteria';
 const routingLogic = JSON.parse(fs.readFileSync(criteriaPath, 'utf8'));
 
-amqp.connect(pipeAddressIn, function(error0, connection) {
+amqp.connect(connectionUrl(pipeAddressIn, 'in'), function(error0, connection) {
   if (error0) {
     throw error0;
   }
@@ -25,9 +25,11 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       throw error1;
     }
 
+    applyPrefetch(channel, 'in');
+
     setupInputPipe(channel, inPipe, pipeTypeIn, inRoutingKey, function(inputQueue) {
-      setupOutputPipe(channel, outOnePipe, pipeTypeOutOne);
-      setupOutputPipe(channel, outTwoPipe, pipeTypeOutTwo);
+      setupOutputPipe(channel, outOnePipe, pipeTypeOutOne, 'outOne');
+      setupOutputPipe(channel, outTwoPipe, pipeTypeOutTwo, 'outTwo');
 
       console.log("Waiting for messages in %s.", inPipe);
 
@@ -66,15 +68,15 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
 
 function setupInputPipe(channel, inPipe, pipeTypeIn, routingKey, callback) {
   if (pipeTypeIn === 'queue') {
-    channel.assertQueue(inPipe);
+    channel.assertQueue(inPipe, queueOptions('in'));
     callback(inPipe);
   } else if (pipeTypeIn === 'topic') {
-    channel.assertExchange(inPipe, 'topic');
+    channel.assertExchange(inPipe, exchangeType('in'));
     channel.assertQueue('', { exclusive: true }, function(error2, q) {
       if (error2) {
         throw error2;
       }
-      channel.bindQueue(q.queue, inPipe, routingKey);
+      channel.bindQueue(q.queue, inPipe, routingKey, bindArguments('in'));
       callback(q.queue);
     });
   } else {
@@ -82,11 +84,11 @@ function setupInputPipe(channel, inPipe, pipeTypeIn, routingKey, callback) {
   }
 }
 
-function setupOutputPipe(channel, pipe, type) {
+function setupOutputPipe(channel, pipe, type, port) {
   if (type === 'queue') {
-    channel.assertQueue(pipe);
+    channel.assertQueue(pipe, queueOptions(port));
   } else if (type === 'topic') {
-    channel.assertExchange(pipe, 'topic');
+    channel.assertExchange(pipe, exchangeType(port));
   } else {
     console.error(`Unknown output pipe type: ${type}`);
   }
@@ -132,3 +134,47 @@ function routeMessageMultiple(message) {
   }
   return destinations;
 }
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
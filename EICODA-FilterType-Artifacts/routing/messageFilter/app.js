@@ -10,7 +10,7 @@ const outRoutingKey = process.env.outRoutingKey || '';
 const criteriaPath = '/etc/config/criteria';
 const filterLogic = JSON.parse(fs.readFileSync(criteriaPath, 'utf8'));
 
-amqp.connect(pipeAddressIn, function(error0, connection) {
+amqp.connect(connectionUrl(pipeAddressIn, 'in'), function(error0, connection) {
   if (error0) {
     throw error0;
   }
@@ -20,8 +20,10 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       throw error1;
     }
 
+    applyPrefetch(channel, 'in');
+
     if (pipeTypeIn === 'queue') {
-      channel.assertQueue(pipeIn);
+      channel.assertQueue(pipeIn, queueOptions('in'));
       console.log("Waiting for messages in queue %s.", pipeIn);
 
       channel.consume(pipeIn, function(msg) {
@@ -31,7 +33,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       });
 
     } else if (pipeTypeIn === 'topic') {
-      channel.assertExchange(pipeIn, 'topic');
+      channel.assertExchange(pipeIn, exchangeType('in'));
       console.log("Waiting for messages on topic exchange %s with topic key %s.", pipeIn, inRoutingKey);
 
       // asserts temporary queue for binding to exchange
@@ -40,7 +42,7 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
           throw error2;
         }
 
-        channel.bindQueue(q.queue, pipeIn, inRoutingKey);
+        channel.bindQueue(q.queue, pipeIn, inRoutingKey, bindArguments('in'));
         channel.consume(q.queue, function(msg) {
           handleIncomingMessage(channel, msg);
         }, {
@@ -59,12 +61,12 @@ function handleIncomingMessage(channel, msg) {
 
   if (filterMessage(message)) {
     if (pipeTypeOut === 'queue') {
-      channel.assertQueue(pipeOut);
+      channel.assertQueue(pipeOut, queueOptions('out'));
       channel.sendToQueue(pipeOut, Buffer.from(JSON.stringify(message)));
       console.log("Sent filtered message to queue %s: %s", pipeOut, JSON.stringify(message));
 
     } else if (pipeTypeOut === 'topic') {
-      channel.assertExchange(pipeOut, 'topic');
+      channel.assertExchange(pipeOut, exchangeType('out'));
       channel.publish(pipeOut, outRoutingKey, Buffer.from(JSON.stringify(message)));
       console.log("Sent filtered message to topic exchange %s with topic key %s: %s", pipeOut, outRoutingKey, JSON.stringify(message));
 
@@ -81,3 +83,47 @@ function handleIncomingMessage(channel, msg) {
 function filterMessage(message) {
   return filterLogic.criterias.every(rule => eval(rule.condition));
 }
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
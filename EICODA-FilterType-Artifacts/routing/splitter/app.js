@@ -9,7 +9,7 @@ const dataToSplit = process.env.data.split(',');
 const source = process.env.source;
 const type = process.env.eventType;
 
-amqp.connect(pipeAddressIn, function(error0, connection) {
+amqp.connect(connectionUrl(pipeAddressIn, 'in'), function(error0, connection) {
   if (error0) {
     throw error0;
   }
@@ -19,6 +19,8 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
       throw error1;
     }
 
+    applyPrefetch(channel, 'in');
+
     setupInputPipe(channel, pipeIn, pipeTypeIn, function(inputQueue) {
       setupOutputPipe(channel, pipeOut, pipeTypeOut);
 
@@ -58,15 +60,15 @@ amqp.connect(pipeAddressIn, function(error0, connection) {
 
 function setupInputPipe(channel, pipeIn, pipeTypeIn, callback) {
   if (pipeTypeIn === 'queue') {
-    channel.assertQueue(pipeIn);
+    channel.assertQueue(pipeIn, queueOptions('in'));
     callback(pipeIn);
   } else if (pipeTypeIn === 'topic') {
-    channel.assertExchange(pipeIn, 'topic');
+    channel.assertExchange(pipeIn, exchangeType('in'));
     channel.assertQueue('', { exclusive: true }, function(error2, q) {
       if (error2) {
         throw error2;
       }
-      channel.bindQueue(q.queue, pipeIn, inRoutingKey);
+      channel.bindQueue(q.queue, pipeIn, inRoutingKey, bindArguments('in'));
       callback(q.queue);
     });
   } else {
@@ -76,9 +78,9 @@ function setupInputPipe(channel, pipeIn, pipeTypeIn, callback) {
 
 function setupOutputPipe(channel, pipeOut, pipeTypeOut) {
   if (pipeTypeOut === 'queue') {
-    channel.assertQueue(pipeOut);
+    channel.assertQueue(pipeOut, queueOptions('out'));
   } else if (pipeTypeOut === 'topic') {
-    channel.assertExchange(pipeOut, 'topic');
+    channel.assertExchange(pipeOut, exchangeType('out'));
   } else {
     console.error(`Unknown output pipe type: ${pipeTypeOut}`);
   }
@@ -100,3 +102,47 @@ function getFieldValue(obj, fieldPath) {
   const adjustedPath = fieldPath.replace(/^message\./, '');
   return adjustedPath.split('.').reduce((o, key) => (o && o[key] !== undefined) ? o[key] : undefined, obj);
 }
+
+//returns the options a queue of a port is declared with. EICODA passes the declaration of queues with configs in
+//<port>QueueDeclaration, RabbitMQ rejects declarations with other settings than the existing queue
+function queueOptions(port) {
+  const declaration = process.env[port + 'QueueDeclaration'];
+  if (!declaration) {
+    return {};
+  }
+  const settings = JSON.parse(declaration);
+  return {
+    durable: settings.durable,
+    autoDelete: settings.autoDelete,
+    exclusive: settings.exclusive,
+    arguments: settings.arguments
+  };
+}
+
+//returns the address of a port in the vhost of its pipe, EICODA passes vhosts other than / in <port>Vhost
+function connectionUrl(address, port) {
+  const vhost = process.env[port + 'Vhost'];
+  return vhost ? address + '/' + encodeURIComponent(vhost) : address;
+}
+
+//returns the type of the exchange of a port, EICODA passes types other than topic in <port>ExchangeType
+function exchangeType(port) {
+  return process.env[port + 'ExchangeType'] || 'topic';
+}
+
+//returns the arguments the queue of a port is bound with, e.g. the headers to match on a headers exchange
+function bindArguments(port) {
+  const args = process.env[port + 'Arguments'];
+  return args ? JSON.parse(args) : {};
+}
+
+//limits the unacknowledged messages of the channel to <port>Prefetch, stream queues can only be consumed with a limit
+function applyPrefetch(channel, port) {
+  const prefetch = parseInt(process.env[port + 'Prefetch'], 10);
+  const args = queueOptions(port).arguments || {};
+  if (prefetch > 0) {
+    channel.prefetch(prefetch);
+  } else if (args['x-queue-type'] === 'stream') {
+    channel.prefetch(100);
+  }
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 	"path/filepath"
 
+	"gopkg.in/yaml.v2"
 	"eicoda/models"
 	"eicoda/plugins"
 	"eicoda/repositoryControllers"
 	"eicoda/transformators"
+	"eicoda/utils"
 )
 
 type ApplicationController struct {
@@ -22,40 +30,145 @@ func NewApplicationController() *ApplicationController {
 	return &ApplicationController{
 		modelParser: NewModelParser(),
 		transformators: map[string]Transformator{
-			"DockerCompose": &transformators.DockerComposeTransformator{},
-			"Kubernetes":    &transformators.KubernetesTransformator{},
-			"RabbitMQ":      &transformators.RabbitMqTransformator{},
+			"DockerCompose":       &transformators.DockerComposeTransformator{},
+			"Kafka":               &transformators.KafkaTransformator{},
+			"Kubernetes":          &transformators.KubernetesTransformator{},
+			"Mosquitto":           &transformators.MosquittoTransformator{},
+			"RabbitMQ":            &transformators.RabbitMqTransformator{},
+			"RabbitMQDefinitions": &transformators.RabbitMqDefinitionsTransformator{},
 		},
 		plugins: map[string]Plugin{
-			"DockerCompose": &plugins.DockerComposePlugin{},
-			"Kubernetes":    &plugins.KubernetesPlugin{},
-			"Terraform":     &plugins.TerraformPlugin{},
+			"DockerCompose":      &plugins.DockerComposePlugin{},
+			"Kubernetes":         &plugins.KubernetesPlugin{},
+			"Terraform":          &plugins.TerraformPlugin{},
+			"RabbitMQManagement": &plugins.RabbitMqManagementPlugin{},
 		},
 		typeController: repositoryControllers.NewTypeController(),
 	}
 }
 
-//handles deployment process
-func (app *ApplicationController) Deploy(path string, measure bool, noTf bool) error {
+//returns the state store of a named deployment
+func (app *ApplicationController) deploymentState(name string) (*repositoryControllers.StateController, error) {
+	name, err := validateDeploymentName(name)
+	if err != nil {
+		return nil, err
+	}
+	return repositoryControllers.NewStateController(name), nil
+}
+
+//checks that a deployment name can be used for compose projects and kubernetes labels, an empty name selects the default deployment
+func validateDeploymentName(name string) (string, error) {
+	if name == "" {
+		return transformators.DefaultDeploymentName, nil
+	}
+	if utils.SanitizeName(name) != name {
+		return "", fmt.Errorf("invalid deployment name %s: only lowercase letters, digits and dashes are allowed", name)
+	}
+	return name, nil
+}
+
+//selects the profiles applied to the parsed models
+func (app *ApplicationController) SetProfiles(names []string) {
+	app.modelParser.SetProfiles(names)
+}
+
+//reads the variable values of a vars file and --set assignments, assignments take precedence over the file
+func (app *ApplicationController) SetVariables(varsFile string, assignments []string) error {
+	values := make(map[string]interface{})
+	if varsFile != "" {
+		fileValues, err := LoadVariablesFile(varsFile)
+		if err != nil {
+			return err
+		}
+		for name, value := range fileValues {
+			values[name] = value
+		}
+	}
+
+	setValues, err := ParseVariableAssignments(assignments)
+	if err != nil {
+		return err
+	}
+	for name, value := range setValues {
+		values[name] = value
+	}
+
+	app.modelParser.SetVariables(values)
+	return nil
+}
+
+//handles deployment process. With managementAPI the RabbitMQ hosts are provisioned from their definitions through
+//the management API, which skips terraform like noTf
+func (app *ApplicationController) Deploy(path string, name string, measure bool, noTf bool, managementAPI bool) error {
 	var startTime, parseTransformTime, endTime time.Time
 
 	if measure {
 		startTime = time.Now()
 	}
 
+	state, err := app.deploymentState(name)
+	if err != nil {
+		return err
+	}
+
+	noTf = noTf || managementAPI
+
 	fmt.Println("Starting deployment process...")
 	model, err := app.modelParser.Parse(path)
 	if err != nil {
 		return fmt.Errorf("failed to parse model: %w", err)
 	}
 
-	//gets dir of the deployment file to pass it to transformators so that they know where to look for the critera files (if there are any)
-	baseDir := filepath.Dir(path)
+	//every deployment that got past parsing is recorded in the state store, regardless of its outcome
+	record := state.NewRecord("deploy")
+	record.ModelPath = path
+	record.Profiles = app.modelParser.Profiles()
+	record.Model = models.RedactCredentials(model)
+	modelData, err := yaml.Marshal(record.Model)
+	if err != nil {
+		return fmt.Errorf("failed to marshal model: %w", err)
+	}
+	record.ModelHash = utils.HashContent(modelData)
 
-	fmt.Println("Transforming model...")
-	if err := app.transformModel(model, baseDir, noTf); err != nil {
+	//reconciles against the last applied deployment so that only changed resources are touched
+	previous, err := state.LastApplied()
+	if err != nil {
+		return fmt.Errorf("failed to read deployment state: %w", err)
+	}
+	if previous != nil {
+		changes := DiffModels(previous.Model, record.Model)
+		fmt.Printf("Updating deployment %s incrementally, %d objects of the model changed.\n", previous.ID, len(changes))
+	}
+
+	if err := os.MkdirAll(state.WorkDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create deployment directory: %w", err)
+	}
+
+	//passes the dir of the deployment file to transformators so that they know where to look for the critera files (if there are any)
+	transformOptions := transformators.Options{
+		WriteFile:      true,
+		BaseDir:        filepath.Dir(path),
+		OutputDir:      state.WorkDir(),
+		DeploymentName: record.Name,
+	}
+
+	//credentials are resolved before anything is transformed, so a missing secret aborts the deployment early
+	secrets, err := transformators.CollectSecrets(model, transformOptions)
+	if err != nil {
+		return fmt.Errorf("failed to resolve credentials: %w", err)
+	}
+	secretsKey, err := state.SecretsKey()
+	if err != nil {
 		return err
 	}
+	record.SecretsHash = hashSecrets(secrets, secretsKey)
+	transformOptions.Secrets = secrets
+
+	fmt.Println("Transforming model...")
+	record.Artifacts, err = app.transformModel(model, transformOptions, noTf)
+	if err != nil {
+		return app.saveRecord(state, record, err)
+	}
 
 	//measures times after parsing and transformation if flag is set
 	if measure {
@@ -63,9 +176,17 @@ func (app *ApplicationController) Deploy(path string, measure bool, noTf bool) e
 		fmt.Printf("TIME TO PARSE AND TRANSFORM: %v\n", parseTransformTime.Sub(startTime))
 	}
 
+	pluginOptions := plugins.ExecutionOptions{
+		Incremental:    previous != nil,
+		DeploymentName: record.Name,
+		WorkDir:        state.WorkDir(),
+		Secrets:        secrets,
+		SecretsChanged: previous != nil && previous.SecretsHash != record.SecretsHash,
+	}
+
 	fmt.Println("Executing plugins...")
-	if err := app.executePlugins(model, noTf); err != nil {
-		return err
+	if err := app.executePlugins(model, noTf, managementAPI, record, previous, pluginOptions); err != nil {
+		return app.saveRecord(state, record, err)
 	}
 
 	fmt.Println("Successfully transformed and deployed model.")
@@ -76,33 +197,397 @@ func (app *ApplicationController) Deploy(path string, measure bool, noTf bool) e
 		fmt.Printf("OVERALL DEPLOYMENT TIME: %v\n", endTime.Sub(startTime))
 	}
 
-	return nil
+	return app.saveRecord(state, record, nil)
 }
 
 //handles destruction process
-func (app *ApplicationController) Destroy() error {
+func (app *ApplicationController) Destroy(name string) error {
+	state, err := app.deploymentState(name)
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("Starting destruction process...")
 
-	fmt.Println("Destroying Kubernetes resources...")
-	if err := app.plugins["Kubernetes"].Destroy(); err != nil {
-		return fmt.Errorf("failed to destroy Kubernetes resources: %w", err)
+	//restores the artifacts of the last deployment so that exactly the deployed resources get destroyed
+	latest, err := state.Latest()
+	if err != nil {
+		return fmt.Errorf("failed to read deployment state: %w", err)
+	}
+	if latest != nil && latest.Action == "deploy" {
+		if err := app.restoreArtifacts(latest.Artifacts, latest.ID); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("No active deployment recorded, using the model files in the deployment directory.")
 	}
 
-	fmt.Println("Destroying DockerCompose resources...")
-	if err := app.plugins["DockerCompose"].Destroy(); err != nil {
-		return fmt.Errorf("failed to destroy DockerCompose resources: %w", err)
+	record := state.NewRecord("destroy")
+	options := plugins.ExecutionOptions{
+		DeploymentName: record.Name,
+		WorkDir:        state.WorkDir(),
+	}
+	if latest != nil {
+		record.ModelPath = latest.ModelPath
+		record.Profiles = latest.Profiles
+		record.ModelHash = latest.ModelHash
+	}
+	//the tools need the credentials to tear down the resources. The recorded model only holds the secret references,
+	//plaintext credentials are taken from the model file and the host repository again. Without them terraform
+	//cannot destroy anything, so the destruction is aborted before any plugin runs
+	if latest != nil && latest.Model != nil {
+		secretOptions := transformators.Options{BaseDir: filepath.Dir(latest.ModelPath), DeploymentName: record.Name}
+		options.Secrets, err = transformators.CollectSecrets(app.restoreCredentials(latest), secretOptions)
+		if err != nil {
+			return fmt.Errorf("failed to resolve credentials: %w", err)
+		}
 	}
 
-	fmt.Println("Destroying Terraform resources...")
-	if err := app.plugins["Terraform"].Destroy(); err != nil {
-		return fmt.Errorf("failed to destroy Terraform resources: %w", err)
+	for _, name := range []string{"Kubernetes", "DockerCompose", "Terraform", "RabbitMQManagement"} {
+		fmt.Printf("Destroying %s resources...\n", name)
+		if err := app.plugins[name].Destroy(options); err != nil {
+			record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusFailed, Error: err.Error()})
+			return app.saveRecord(state, record, fmt.Errorf("failed to destroy %s resources: %w", name, err))
+		}
+		record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusDestroyed})
 	}
 
 	fmt.Println("Successfully destroyed all resources.")
+	if err := app.saveRecord(state, record, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+//writes back artifacts of a recorded deployment that were modified or removed in the meantime
+func (app *ApplicationController) restoreArtifacts(artifacts []models.ArtifactRecord, recordID string) error {
+	for _, artifact := range artifacts {
+		data, err := os.ReadFile(artifact.Path)
+		if err == nil && utils.HashContent(data) == artifact.Hash {
+			continue
+		}
+
+		fmt.Printf("Restoring %s from deployment %s as it was modified or removed.\n", artifact.Path, recordID)
+		err = os.WriteFile(artifact.Path, []byte(artifact.Content), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", artifact.Path, err)
+		}
+	}
+	return nil
+}
+
+//prints the latest recorded deployment and whether its artifacts still match the files on disk
+func (app *ApplicationController) Status(name string) error {
+	state, err := app.deploymentState(name)
+	if err != nil {
+		return err
+	}
+
+	latest, err := state.Latest()
+	if err != nil {
+		return fmt.Errorf("failed to read deployment state: %w", err)
+	}
+	if latest == nil {
+		fmt.Println("No deployment recorded.")
+		return nil
+	}
+
+	fmt.Printf("Name:       %s\n", latest.Name)
+	fmt.Printf("Deployment: %s\n", latest.ID)
+	fmt.Printf("Action:     %s\n", latest.Action)
+	fmt.Printf("Status:     %s\n", latest.Status)
+	fmt.Printf("Time:       %s\n", latest.Timestamp)
+	fmt.Printf("Model:      %s\n", latest.ModelPath)
+	if len(latest.Profiles) > 0 {
+		fmt.Printf("Profiles:   %s\n", strings.Join(latest.Profiles, ", "))
+	}
+	fmt.Printf("Model hash: %s\n", latest.ModelHash)
+	if latest.Error != "" {
+		fmt.Printf("Error:      %s\n", latest.Error)
+	}
+
+	if len(latest.Artifacts) > 0 {
+		fmt.Println("Artifacts:")
+		for _, artifact := range latest.Artifacts {
+			state := "in sync"
+			data, err := os.ReadFile(artifact.Path)
+			if err != nil {
+				state = "missing"
+			} else if utils.HashContent(data) != artifact.Hash {
+				state = "modified"
+			}
+			fmt.Printf("  %-19s %-24s %s\n", artifact.Transformator, artifact.Path, state)
+		}
+	}
+
+	if len(latest.Plugins) > 0 {
+		fmt.Println("Plugins:")
+		for _, plugin := range latest.Plugins {
+			if plugin.Error != "" {
+				fmt.Printf("  %-19s %s (%s)\n", plugin.Name, plugin.Status, plugin.Error)
+			} else {
+				fmt.Printf("  %-19s %s\n", plugin.Name, plugin.Status)
+			}
+		}
+	}
+
+	return nil
+}
+
+//prints every known deployment with its latest recorded run
+func (app *ApplicationController) ListDeployments() error {
+	names, err := repositoryControllers.ListDeployments()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("No deployment recorded.")
+		return nil
+	}
+
+	for _, name := range names {
+		latest, err := repositoryControllers.NewStateController(name).Latest()
+		if err != nil {
+			return fmt.Errorf("failed to read state of deployment %s: %w", name, err)
+		}
+		if latest == nil {
+			fmt.Printf("%-20s no runs recorded\n", name)
+			continue
+		}
+		fmt.Printf("%-20s %-8s %-10s %s  %s\n", name, latest.Action, latest.Status, latest.Timestamp, latest.ModelPath)
+	}
+	return nil
+}
+
+//prints all recorded deploy and destroy runs, oldest first
+func (app *ApplicationController) History(name string) error {
+	state, err := app.deploymentState(name)
+	if err != nil {
+		return err
+	}
+
+	records, err := state.History()
+	if err != nil {
+		return fmt.Errorf("failed to read deployment state: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No deployment recorded.")
+		return nil
+	}
+
+	for _, record := range records {
+		hash := record.ModelHash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Printf("%s  %-8s %-10s %-12s %s\n", record.ID, record.Action, record.Status, hash, record.ModelPath)
+	}
+	return nil
+}
+
+//prints the changes a deployment of the model would make compared with the last applied deployment
+func (app *ApplicationController) Plan(path string, name string) error {
+	state, err := app.deploymentState(name)
+	if err != nil {
+		return err
+	}
+
+	model, err := app.modelParser.Parse(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	lastApplied, err := state.LastApplied()
+	if err != nil {
+		return fmt.Errorf("failed to read deployment state: %w", err)
+	}
+
+	var appliedModel *models.Model
+	if lastApplied != nil {
+		fmt.Printf("Comparing with deployment %s (%s).\n", lastApplied.ID, lastApplied.ModelPath)
+		appliedModel = lastApplied.Model
+	} else {
+		fmt.Println("No applied deployment recorded, comparing with an empty model.")
+	}
+
+	changes := DiffModels(appliedModel, models.RedactCredentials(model))
+	if len(changes) == 0 {
+		fmt.Println("No changes. The deployment matches the model.")
+		return nil
+	}
+
+	symbols := map[string]string{ChangeAdd: "+", ChangeRemove: "-", ChangeUpdate: "~"}
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Action]++
+		fmt.Printf("  %s %s %s\n", symbols[change.Action], change.Kind, change.Name)
+		for _, detail := range change.Details {
+			fmt.Printf("      %s\n", detail)
+		}
+	}
+	fmt.Printf("Plan: %d to add, %d to change, %d to remove.\n", counts[ChangeAdd], counts[ChangeUpdate], counts[ChangeRemove])
+
 	return nil
 }
 
-func (app *ApplicationController) ProcessModel(content string) ([]string, error) {
+//renders the topology of a model as a diagram and prints it or writes it to a file
+func (app *ApplicationController) Graph(path string, format string, outPath string) error {
+	model, err := app.modelParser.Parse(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	diagram, err := ExportGraph(model, format)
+	if err != nil {
+		return err
+	}
+
+	if outPath == "" {
+		fmt.Print(diagram)
+		return nil
+	}
+
+	err = os.WriteFile(outPath, []byte(diagram), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write graph to %s: %w", outPath, err)
+	}
+	fmt.Printf("Graph written to %s\n", outPath)
+	return nil
+}
+
+//generates a JSON Schema for deployment models from the type repository and prints it or writes it to a file
+func (app *ApplicationController) Schema(outPath string) error {
+	combinedTypes, err := app.modelParser.LoadTypes()
+	if err != nil {
+		return err
+	}
+
+	combinedTypes.FilterTypes, err = models.ResolveFilterTypes(combinedTypes.FilterTypes)
+	if err != nil {
+		return fmt.Errorf("invalid filter types: %w", err)
+	}
+
+	schema, err := GenerateSchema(combinedTypes, app.modelParser.HostTypes())
+	if err != nil {
+		return err
+	}
+
+	if outPath == "" {
+		fmt.Print(string(schema))
+		return nil
+	}
+
+	err = os.WriteFile(outPath, schema, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write schema to %s: %w", outPath, err)
+	}
+	fmt.Printf("Schema written to %s\n", outPath)
+	return nil
+}
+
+//prints a filter type of the type repository with its inheritance resolved and the source of each config
+func (app *ApplicationController) ShowType(name string) error {
+	combinedTypes, err := app.modelParser.LoadTypes()
+	if err != nil {
+		return err
+	}
+
+	resolvedTypes, err := models.ResolveFilterTypes(combinedTypes.FilterTypes)
+	if err != nil {
+		return fmt.Errorf("invalid filter types: %w", err)
+	}
+	filterType := utils.FindFilterTypeByName(resolvedTypes, name)
+	if filterType == nil {
+		return fmt.Errorf("filter type %s not found", name)
+	}
+
+	//the hierarchy from the type up to its root, taken from the unresolved types
+	hierarchy := []string{filterType.Name}
+	artifactSource := ""
+	current := utils.FindFilterTypeByName(combinedTypes.FilterTypes, name)
+	for current != nil {
+		if artifactSource == "" && current.Artifact != "" {
+			artifactSource = current.Name
+		}
+		if current.DerivedFrom == "" {
+			break
+		}
+		hierarchy = append(hierarchy, current.DerivedFrom)
+		current = utils.FindFilterTypeByName(combinedTypes.FilterTypes, current.DerivedFrom)
+	}
+
+	fmt.Printf("Filter type: %s\n", filterType.Name)
+	if filterType.Abstract {
+		fmt.Println("Abstract: yes, filters cannot use this type directly")
+	}
+	if filterType.Stateful {
+		fmt.Println("Stateful: yes, filters of this type cannot have more than one replica")
+	}
+	if len(hierarchy) > 1 {
+		fmt.Printf("Hierarchy: %s\n", strings.Join(hierarchy, " -> "))
+	}
+	if filterType.Artifact != "" {
+		fmt.Printf("Artifact: %s (from %s)\n", filterType.Artifact, artifactSource)
+	}
+
+	if len(filterType.Configs) == 0 {
+		fmt.Println("Configs: none")
+		return nil
+	}
+	fmt.Println("Configs:")
+	for _, config := range filterType.Configs {
+		valueType := config.Type
+		if config.IsFile() {
+			valueType = models.ConfigTypeFile
+		}
+		if valueType == "" {
+			valueType = "untyped"
+		}
+		if config.Type == models.ConfigTypeEnum {
+			valueType = fmt.Sprintf("enum(%s)", strings.Join(config.Values, ", "))
+		}
+
+		requirement := "required"
+		if config.Default != nil {
+			requirement = fmt.Sprintf("default %v", config.Default)
+		}
+
+		source := "defined in " + config.Source
+		if config.OverriddenIn != "" {
+			source += ", overridden in " + config.OverriddenIn
+		}
+
+		fmt.Printf("  %s: %s, %s (%s)\n", config.Name, valueType, requirement, source)
+		if config.Description != "" {
+			fmt.Printf("      %s\n", config.Description)
+		}
+	}
+
+	return nil
+}
+
+//persists the record with the outcome of the run and passes the original error through
+func (app *ApplicationController) saveRecord(state *repositoryControllers.StateController, record *models.DeploymentRecord, runErr error) error {
+	record.Status = models.StatusSucceeded
+	if record.Action == "destroy" {
+		record.Status = models.StatusDestroyed
+	}
+	if runErr != nil {
+		record.Status = models.StatusFailed
+		record.Error = runErr.Error()
+	}
+
+	if err := state.SaveRecord(record); err != nil {
+		if runErr != nil {
+			fmt.Printf("Failed to record deployment state: %v\n", err)
+			return runErr
+		}
+		return fmt.Errorf("failed to record deployment state: %w", err)
+	}
+	return runErr
+}
+
+func (app *ApplicationController) ProcessModel(content string, showExpanded bool) ([]string, error) {
 	fmt.Println("Processing model content...")
 	model, err := app.modelParser.ParseFromString(content)
 	if err != nil {
@@ -111,11 +596,22 @@ func (app *ApplicationController) ProcessModel(content string) ([]string, error)
 
 	var results []string
 
-	baseDir := filepath.Dir(".")
+	if showExpanded {
+		expanded, err := app.modelParser.Expand(content)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fmt.Sprintf("Expanded Model:\n%s", expanded))
+	}
+
+	options := transformators.Options{
+		BaseDir:        filepath.Dir("."),
+		DeploymentName: transformators.DefaultDeploymentName,
+	}
 
 	fmt.Println("Transforming model with appropriate transformators...")
-	for name, transformator := range app.transformators {
-		transformedModel, err := transformator.Transform(model, false, baseDir)
+	for _, name := range app.transformatorNames() {
+		transformedModel, err := app.transformators[name].Transform(model, options)
 		if err != nil {
 			return nil, fmt.Errorf("failed to transform model with %s: %w", name, err)
 		}
@@ -125,72 +621,356 @@ func (app *ApplicationController) ProcessModel(content string) ([]string, error)
 	return results, nil
 }
 
-func (app *ApplicationController) transformModel(model *models.Model, baseDir string, noTf bool) error {
+//renders the target models of a deployment model into outDir without executing any plugin, one subdirectory per target
+func (app *ApplicationController) Generate(path string, outDir string, name string, targets []string) error {
+	name, err := validateDeploymentName(name)
+	if err != nil {
+		return err
+	}
+
+	model, err := app.modelParser.Parse(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	selected, err := app.selectTargets(model, targets)
+	if err != nil {
+		return err
+	}
+
+	fileNames := map[string]string{
+		"DockerCompose":       transformators.DockerComposeModelFile,
+		"Kafka":               transformators.KafkaModelFile,
+		"Kubernetes":          transformators.KubernetesModelFile,
+		"Mosquitto":           transformators.MosquittoModelFile,
+		"RabbitMQ":            transformators.RabbitMqModelFile,
+		"RabbitMQDefinitions": transformators.RabbitMqDefinitionsModelFile,
+	}
+
+	for _, target := range selected {
+		targetDir := filepath.Join(outDir, strings.ToLower(target))
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory %s: %w", targetDir, err)
+		}
+
+		options := transformators.Options{
+			WriteFile:      true,
+			BaseDir:        filepath.Dir(path),
+			OutputDir:      targetDir,
+			DeploymentName: name,
+			PortableFiles:  true,
+		}
+		if _, err := app.transformators[target].Transform(model, options); err != nil {
+			return fmt.Errorf("failed to transform model with %s: %w", target, err)
+		}
+		fmt.Printf("Generated %s\n", options.OutputPath(fileNames[target]))
+	}
+
+	return nil
+}
+
+//resolves the requested target names case insensitively, without a selection all targets the model needs are returned in a fixed order
+func (app *ApplicationController) selectTargets(model *models.Model, targets []string) ([]string, error) {
+	available := app.transformatorNames()
+	if len(targets) == 0 {
+		var needed []string
+		for _, name := range available {
+			if app.isTransformationNeeded(name, model) {
+				needed = append(needed, name)
+			}
+		}
+		return needed, nil
+	}
+
+	var selected []string
+	for _, target := range targets {
+		found := false
+		for _, name := range available {
+			if strings.EqualFold(strings.ReplaceAll(target, "-", ""), name) {
+				if !contains(selected, name) {
+					selected = append(selected, name)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown target %s, available targets are: %s", target, strings.Join(available, ", "))
+		}
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+//returns the names of all registered transformators in a fixed order
+func (app *ApplicationController) transformatorNames() []string {
+	var names []string
+	for name := range app.transformators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//checks if the model contains resources for the given transformator
+func (app *ApplicationController) isTransformationNeeded(name string, model *models.Model) bool {
+	switch name {
+	case "DockerCompose":
+		return app.shouldTransformDockerCompose(model)
+	case "Kafka":
+		return app.shouldTransformKafka(model)
+	case "Kubernetes":
+		return app.shouldTransformKubernetes(model)
+	case "Mosquitto":
+		return app.shouldTransformMosquitto(model)
+	case "RabbitMQ", "RabbitMQDefinitions":
+		return app.shouldTransformRabbitMQ(model)
+	}
+	return false
+}
+
+func (app *ApplicationController) transformModel(model *models.Model, options transformators.Options, noTf bool) ([]models.ArtifactRecord, error) {
+	var artifacts []models.ArtifactRecord
+
 	fmt.Println("Checking if transformation for DockerCompose is needed...")
 	if app.shouldTransformDockerCompose(model) {
 		fmt.Println("Transforming model for DockerCompose...")
-		if _, err := app.transformators["DockerCompose"].Transform(model, true, baseDir); err != nil {
-			return fmt.Errorf("failed to transform model with DockerCompose: %w", err)
+		artifact, err := app.runTransformator("DockerCompose", transformators.DockerComposeModelFile, model, options)
+		if err != nil {
+			return artifacts, err
 		}
+		artifacts = append(artifacts, artifact)
 	}
 	fmt.Println("Checking if transformation for Kubernetes is needed...")
 	if app.shouldTransformKubernetes(model) {
 		fmt.Println("Transforming model for Kubernetes...")
-		if _, err := app.transformators["Kubernetes"].Transform(model, true, baseDir); err != nil {
-			return fmt.Errorf("failed to transform model with Kubernetes: %w", err)
+		artifact, err := app.runTransformator("Kubernetes", transformators.KubernetesModelFile, model, options)
+		if err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	//there is no plugin for Kafka, the topics are applied to a Strimzi cluster or created with the generated script
+	fmt.Println("Checking if transformation for Kafka is needed...")
+	if app.shouldTransformKafka(model) {
+		fmt.Println("Transforming model for Kafka...")
+		artifact, err := app.runTransformator("Kafka", transformators.KafkaModelFile, model, options)
+		if err != nil {
+			return artifacts, err
 		}
+		artifacts = append(artifacts, artifact)
+		fmt.Printf("Kafka topics written to %s, apply them to the Strimzi cluster or run %s.\n", artifact.Path, options.OutputPath(transformators.KafkaScriptFile))
+	}
+	//there is no plugin for Mosquitto, the broker loads the generated configuration on its own
+	fmt.Println("Checking if transformation for Mosquitto is needed...")
+	if app.shouldTransformMosquitto(model) {
+		fmt.Println("Transforming model for Mosquitto...")
+		artifact, err := app.runTransformator("Mosquitto", transformators.MosquittoModelFile, model, options)
+		if err != nil {
+			return artifacts, err
+		}
+		artifacts = append(artifacts, artifact)
+		fmt.Printf("Mosquitto configuration written to %s, copy the files of each host to /mosquitto/config of its broker.\n",
+			options.OutputPath(fmt.Sprintf(transformators.MosquittoConfigFile, "<host>")))
 	}
 	if !noTf {
 		fmt.Println("Checking if transformation for RabbitMQ is needed...")
 		if app.shouldTransformRabbitMQ(model) {
 			fmt.Println("Transforming model for RabbitMQ...")
-			if _, err := app.transformators["RabbitMQ"].Transform(model, true, baseDir); err != nil {
-				return fmt.Errorf("failed to transform model with RabbitMQ: %w", err)
+			artifact, err := app.runTransformator("RabbitMQ", transformators.RabbitMqModelFile, model, options)
+			if err != nil {
+				return artifacts, err
 			}
+			artifacts = append(artifacts, artifact)
 		}
 	} else {
-		fmt.Println("Skipping Terraform transformations as --no-tf flag is set.")
+		//without terraform the broker loads the definitions on its own or they are imported through the management API
+		fmt.Println("Skipping Terraform transformations as --no-tf or --rabbitmq-api is set.")
+		if app.shouldTransformRabbitMQ(model) {
+			fmt.Println("Transforming model for RabbitMQ definitions...")
+			artifact, err := app.runTransformator("RabbitMQDefinitions", transformators.RabbitMqDefinitionsModelFile, model, options)
+			if err != nil {
+				return artifacts, err
+			}
+			artifacts = append(artifacts, artifact)
+			fmt.Printf("RabbitMQ definitions written to %s, load them with load_definitions or import them through the management API.\n",
+				options.OutputPath(fmt.Sprintf(transformators.RabbitMqDefinitionsFile, "<host>")))
+		}
 	}
-	return nil
+	return artifacts, nil
 }
 
-func (app *ApplicationController) executePlugins(model *models.Model, noTf bool) error {
+//runs a transformator that writes its model to the output directory and returns the record of the written artifact
+func (app *ApplicationController) runTransformator(name string, fileName string, model *models.Model, options transformators.Options) (models.ArtifactRecord, error) {
+	content, err := app.transformators[name].Transform(model, options)
+	if err != nil {
+		return models.ArtifactRecord{}, fmt.Errorf("failed to transform model with %s: %w", name, err)
+	}
+
+	return models.ArtifactRecord{
+		Transformator: name,
+		Path:          options.OutputPath(fileName),
+		Hash:          utils.HashContent([]byte(content)),
+		Content:       content,
+	}, nil
+}
+
+func (app *ApplicationController) executePlugins(model *models.Model, noTf bool, managementAPI bool, record *models.DeploymentRecord, previous *models.DeploymentRecord, options plugins.ExecutionOptions) error {
 
 	//handles errors if anything goes seriously wrong during program execution
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("A panic occurred during plugin execution, initiating cleanup...")
-			app.cleanupPlugins()
+			if !options.Incremental {
+				app.cleanupPlugins(options)
+			}
 			panic(r)
 		}
 	}()
 
 	if !noTf {
 		fmt.Println("Executing Terraform plugin if needed...")
-		if app.shouldTransformRabbitMQ(model) {
-			if err := app.plugins["Terraform"].Execute(); err != nil {
-				fmt.Printf("Terraform plugin execution failed: %v. Initiating cleanup...\n", err)
-				app.cleanupPlugins()
-				return fmt.Errorf("Terraform plugin execution failed: %w", err)
-			}
+		if err := app.runPlugin("Terraform", "RabbitMQ", app.shouldTransformRabbitMQ(model), record, previous, options); err != nil {
+			return err
 		}
 	} else {
-		fmt.Println("Skipping Terraform plugin execution as --no-tf flag is set.")
+		fmt.Println("Skipping Terraform plugin execution as --no-tf or --rabbitmq-api is set.")
+		record.Plugins = append(record.Plugins, models.PluginRecord{Name: "Terraform", Status: models.StatusSkipped})
 	}
-	fmt.Println("Executing Kubernetes plugin if needed...")
-	if app.shouldTransformKubernetes(model) {
-		if err := app.plugins["Kubernetes"].Execute(); err != nil {
-			fmt.Printf("Kubernetes plugin execution failed: %v. Initiating cleanup...\n", err)
-			app.cleanupPlugins()
-			return fmt.Errorf("Kubernetes plugin execution failed: %w", err)
+	//without the flag the definitions are only written, e.g. for load_definitions of the broker
+	if managementAPI {
+		//--no-tf writes the same definitions without applying them, so they only count as deployed if the plugin ran
+		applied := previous
+		if !pluginApplied(previous, "RabbitMQManagement") {
+			applied = nil
+		}
+		fmt.Println("Executing RabbitMQManagement plugin if needed...")
+		if err := app.runPlugin("RabbitMQManagement", "RabbitMQDefinitions", app.shouldTransformRabbitMQ(model), record, applied, options); err != nil {
+			return err
 		}
 	}
+	fmt.Println("Executing Kubernetes plugin if needed...")
+	if err := app.runPlugin("Kubernetes", "Kubernetes", app.shouldTransformKubernetes(model), record, previous, options); err != nil {
+		return err
+	}
 	fmt.Println("Executing DockerCompose plugin if needed...")
-	if app.shouldTransformDockerCompose(model) {
-		if err := app.plugins["DockerCompose"].Execute(); err != nil {
-			fmt.Printf("DockerCompose plugin execution failed: %v. Initiating cleanup...\n", err)
-			app.cleanupPlugins()
-			return fmt.Errorf("DockerCompose plugin execution failed: %w", err)
+	if err := app.runPlugin("DockerCompose", "DockerCompose", app.shouldTransformDockerCompose(model), record, previous, options); err != nil {
+		return err
+	}
+	return nil
+}
+
+//reconciles the target of a plugin against the previous deployment and records the outcome.
+//unchanged targets are left alone, targets no longer in the model are destroyed and a failed
+//fresh deployment cleans up all resources, while a failed incremental one keeps the running resources
+func (app *ApplicationController) runPlugin(name string, transformatorName string, needed bool, record *models.DeploymentRecord, previous *models.DeploymentRecord, options plugins.ExecutionOptions) error {
+	previousArtifact := findArtifactRecord(previous, transformatorName)
+
+	if !needed {
+		if previousArtifact == nil {
+			record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusSkipped})
+			return nil
+		}
+
+		fmt.Printf("%s resources are no longer part of the model, destroying them...\n", name)
+		if err := app.restoreArtifacts([]models.ArtifactRecord{*previousArtifact}, previous.ID); err != nil {
+			return err
+		}
+		if err := app.plugins[name].Destroy(options); err != nil {
+			record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusFailed, Error: err.Error()})
+			return fmt.Errorf("failed to destroy %s resources: %w", name, err)
+		}
+		record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusDestroyed})
+		return nil
+	}
+
+	currentArtifact := findArtifactRecord(record, transformatorName)
+	if previousArtifact != nil && currentArtifact != nil && previousArtifact.Hash == currentArtifact.Hash && !options.SecretsChanged {
+		fmt.Printf("%s resources are unchanged since deployment %s, skipping.\n", name, previous.ID)
+		record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusUnchanged})
+		return nil
+	}
+
+	if err := app.plugins[name].Execute(options); err != nil {
+		record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusFailed, Error: err.Error()})
+		if options.Incremental {
+			fmt.Printf("%s plugin execution failed: %v. Keeping the previously deployed resources.\n", name, err)
+		} else {
+			fmt.Printf("%s plugin execution failed: %v. Initiating cleanup...\n", name, err)
+			app.cleanupPlugins(options)
+		}
+		return fmt.Errorf("%s plugin execution failed: %w", name, err)
+	}
+
+	record.Plugins = append(record.Plugins, models.PluginRecord{Name: name, Status: models.StatusSucceeded})
+	return nil
+}
+
+//returns the recorded model with the redacted credentials of its pipe hosts taken from the model file the deployment
+//was created from. Credentials that cannot be restored stay redacted and are resolved from the environment
+func (app *ApplicationController) restoreCredentials(record *models.DeploymentRecord) *models.Model {
+	restored := *record.Model
+	restored.Hosts.PipeHosts = make([]models.Host, len(record.Model.Hosts.PipeHosts))
+	copy(restored.Hosts.PipeHosts, record.Model.Hosts.PipeHosts)
+
+	app.modelParser.SetProfiles(record.Profiles)
+	model, err := app.modelParser.Parse(record.ModelPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to parse model %s to restore the credentials: %v\n", record.ModelPath, err)
+		return &restored
+	}
+
+	for i := range restored.Hosts.PipeHosts {
+		host := &restored.Hosts.PipeHosts[i]
+		parsed := utils.FindHostByName(model.Hosts.PipeHosts, host.Name)
+		if parsed == nil {
+			continue
+		}
+		props := make(map[string]string, len(host.AdditionalProps))
+		for key, value := range host.AdditionalProps {
+			if value == models.RedactedValue && models.IsCredentialProp(key) && parsed.AdditionalProps[key] != "" {
+				value = parsed.AdditionalProps[key]
+			}
+			props[key] = value
+		}
+		host.AdditionalProps = props
+	}
+	return &restored
+}
+
+//returns an HMAC over the resolved credentials, so a deployment can tell whether they changed without recording them
+func hashSecrets(secrets []models.Secret, key []byte) string {
+	if len(secrets) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	for _, secret := range secrets {
+		mac.Write([]byte(secret.EnvName + "=" + secret.Value + "\n"))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//checks if a plugin applied its model in a recorded deployment, either now or in an earlier one it was unchanged since
+func pluginApplied(record *models.DeploymentRecord, name string) bool {
+	if record == nil {
+		return false
+	}
+	for _, plugin := range record.Plugins {
+		if plugin.Name == name {
+			return plugin.Status == models.StatusSucceeded || plugin.Status == models.StatusUnchanged
+		}
+	}
+	return false
+}
+
+//finds the artifact a transformator generated for a recorded deployment
+func findArtifactRecord(record *models.DeploymentRecord, transformatorName string) *models.ArtifactRecord {
+	if record == nil {
+		return nil
+	}
+	for i, artifact := range record.Artifacts {
+		if artifact.Transformator == transformatorName {
+			return &record.Artifacts[i]
 		}
 	}
 	return nil
@@ -223,41 +1003,63 @@ func (app *ApplicationController) shouldTransformKubernetes(model *models.Model)
 }
 
 func (app *ApplicationController) shouldTransformRabbitMQ(model *models.Model) bool {
+	return app.hasPipesOnHostType(model, "RabbitMQ")
+}
+
+func (app *ApplicationController) shouldTransformMosquitto(model *models.Model) bool {
+	return app.hasPipesOnHostType(model, "Mosquitto")
+}
+
+func (app *ApplicationController) shouldTransformKafka(model *models.Model) bool {
+	return app.hasPipesOnHostType(model, "Kafka")
+}
+
+//checks if a queue or topic of the model is placed on a pipe host of the given type
+func (app *ApplicationController) hasPipesOnHostType(model *models.Model, hostType string) bool {
 	for _, host := range model.Hosts.PipeHosts {
-		if host.Type == "RabbitMQ" {
+		if host.Type == hostType {
 			for _, queue := range model.Pipes.Queues {
 				if queue.Host == host.Name {
 					return true
 				}
 			}
+			for _, topic := range model.Pipes.Topics {
+				if topic.Host == host.Name {
+					return true
+				}
+			}
 		}
 	}
 	return false
 }
 
 // handle clean up if any of the plugins fail to execute the deployment
-func (app *ApplicationController) cleanupPlugins() {
+func (app *ApplicationController) cleanupPlugins(options plugins.ExecutionOptions) {
 	fmt.Println("Starting cleanup process...")
 
-	if err := app.plugins["Kubernetes"].Destroy(); err != nil {
+	if err := app.plugins["Kubernetes"].Destroy(options); err != nil {
 		fmt.Printf("Failed to destroy Kubernetes resources during cleanup: %v\n", err)
 	}
 
-	if err := app.plugins["DockerCompose"].Destroy(); err != nil {
+	if err := app.plugins["DockerCompose"].Destroy(options); err != nil {
 		fmt.Printf("Failed to destroy DockerCompose resources during cleanup: %v\n", err)
 	}
 
-	if err := app.plugins["Terraform"].Destroy(); err != nil {
+	if err := app.plugins["Terraform"].Destroy(options); err != nil {
 		fmt.Printf("Failed to destroy Terraform resources during cleanup: %v\n", err)
 	}
 
+	if err := app.plugins["RabbitMQManagement"].Destroy(options); err != nil {
+		fmt.Printf("Failed to destroy RabbitMQManagement resources during cleanup: %v\n", err)
+	}
+
 	fmt.Println("Cleanup process completed.")
 }
 
 //defines necessary interface of plugins
 type Plugin interface {
-	Execute() error
-	Destroy() error
+	Execute(options plugins.ExecutionOptions) error
+	Destroy(options plugins.ExecutionOptions) error
 }
 
 func (app *ApplicationController) AddType(path string) error {
@@ -14,6 +14,15 @@ var rootCmd = &cobra.Command{
 	Use:   "eicoda",
 	Short: "EICODA is a CLI tool for deploying pipes and filters architectures",
 	Long:  `EICODA is a CLI tool designed to help you deploy pipes and filters architecture configurations using YAML files.`,
+	//passes the variable values of --vars and --set and the profiles of --profile to the model parser before any command runs
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		profiles, _ := cmd.Flags().GetStringSlice("profile")
+		appController.SetProfiles(profiles)
+
+		varsFile, _ := cmd.Flags().GetString("vars")
+		assignments, _ := cmd.Flags().GetStringArray("set")
+		return appController.SetVariables(varsFile, assignments)
+	},
 }
 
 //start deploy process
@@ -25,12 +34,14 @@ var deployCmd = &cobra.Command{
 		path, _ := cmd.Flags().GetString("path")
 		measure, _ := cmd.Flags().GetBool("measure")
 		noTf, _ := cmd.Flags().GetBool("no-tf")
+		managementAPI, _ := cmd.Flags().GetBool("rabbitmq-api")
+		name, _ := cmd.Flags().GetString("name")
 		if path == "" {
 			fmt.Println("Path to the deployment YAML file is required.")
 			return
 		}
 		
-		err := appController.Deploy(path, measure, noTf)
+		err := appController.Deploy(path, name, measure, noTf, managementAPI)
 		if err != nil {
 			fmt.Printf("Deployment failed: %v\n", err)
 		}
@@ -62,11 +73,12 @@ var processCmd = &cobra.Command{
 	Long:  `Process a deployment model and return the transformed models.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		content, _ := cmd.Flags().GetString("content")
+		expanded, _ := cmd.Flags().GetBool("expanded")
 		if content == "" {
 			fmt.Println("Content of the deployment model is required.")
 			return
 		}
-		results, err := appController.ProcessModel(content)
+		results, err := appController.ProcessModel(content, expanded)
 		if err != nil {
 			fmt.Printf("Processing failed: %v\n", err)
 			return
@@ -77,13 +89,14 @@ var processCmd = &cobra.Command{
 	},
 }
 
-//destroy deployed resources. Uses files that are in kubernetesModel.yaml, rabbitMqModel.yaml and docker-compose.yaml relative to the eicoda binary
+//destroy deployed resources. Uses the kubernetesModel.yaml, rabbitMqModel.tf and docker-compose.yaml files of the deployment in .eicoda/deployments/<name>
 var destroyCmd = &cobra.Command{
 	Use:   "destroy",
 	Short: "Destroy a deployment",
 	Long:  `Destroy a deployment that was previously created.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := appController.Destroy()
+		name, _ := cmd.Flags().GetString("name")
+		err := appController.Destroy(name)
 		if err != nil {
 			fmt.Printf("Destroy failed: %v\n", err)
 		} else {
@@ -92,23 +105,194 @@ var destroyCmd = &cobra.Command{
 	},
 }
 
+//renders the target models into a directory without deploying them, e.g. for GitOps tools
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate the target models without deploying them",
+	Long:  `Render the DockerCompose, Kafka, Kubernetes, Mosquitto, RabbitMQ/Terraform and RabbitMQ definitions models of a deployment model into an output directory without executing any plugin.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		out, _ := cmd.Flags().GetString("out")
+		name, _ := cmd.Flags().GetString("name")
+		targets, _ := cmd.Flags().GetStringSlice("targets")
+		if path == "" || out == "" {
+			fmt.Println("Path to the deployment YAML file and output directory are required.")
+			return
+		}
+
+		err := appController.Generate(path, out, name, targets)
+		if err != nil {
+			fmt.Printf("Generation failed: %v\n", err)
+		}
+	},
+}
+
+//shows what a deployment of the model would change compared with the last applied deployment
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes a deployment would make",
+	Long:  `Compare a deployment model with the last applied deployment and list the filters, pipes, hosts and config values that would be added, removed or changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		name, _ := cmd.Flags().GetString("name")
+		if path == "" {
+			fmt.Println("Path to the deployment YAML file is required.")
+			return
+		}
+
+		err := appController.Plan(path, name)
+		if err != nil {
+			fmt.Printf("Plan failed: %v\n", err)
+		}
+	},
+}
+
+//exports the pipes-and-filters topology of a model as a diagram
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export the topology of a model as a diagram",
+	Long:  `Render the filters, queues and topics of a deployment model grouped by host as DOT, Mermaid or PlantUML diagram in EIP notation.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+		if path == "" {
+			fmt.Println("Path to the deployment YAML file is required.")
+			return
+		}
+
+		err := appController.Graph(path, format, out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Graph export failed: %v\n", err)
+		}
+	},
+}
+
+//emits a JSON Schema of deployment models for editor support
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Generate a JSON Schema for deployment models",
+	Long:  `Generate a JSON Schema for deployment models from the current type repository, listing the allowed and required properties of every filter and host type.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+
+		err := appController.Schema(out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Schema generation failed: %v\n", err)
+		}
+	},
+}
+
+//groups the commands inspecting the filter types of the type repository
+var typesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "Inspect the filter types of the type repository",
+}
+
+//shows a filter type with its inheritance resolved
+var typesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a filter type with its inheritance resolved",
+	Long:  `Show a filter type of the type repository with its inheritance resolved, including the type each config is defined and overridden in.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := appController.ShowType(args[0])
+		if err != nil {
+			fmt.Printf("Showing filter type failed: %v\n", err)
+		}
+	},
+}
+
+//shows the latest recorded deployment and whether its generated files were modified since
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the current deployment",
+	Long:  `Show the latest recorded deployment, its generated artifacts and the outcome of each plugin.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		all, _ := cmd.Flags().GetBool("all")
+		var err error
+		if all {
+			err = appController.ListDeployments()
+		} else {
+			err = appController.Status(name)
+		}
+		if err != nil {
+			fmt.Printf("Status failed: %v\n", err)
+		}
+	},
+}
+
+//lists all recorded deploy and destroy runs
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the deployment history",
+	Long:  `List all recorded deploy and destroy runs, oldest first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		err := appController.History(name)
+		if err != nil {
+			fmt.Printf("History failed: %v\n", err)
+		}
+	},
+}
+
 func init() {
 	appController = NewApplicationController()
+	rootCmd.PersistentFlags().String("vars", "", "YAML file with values for the variables of the deployment model")
+	rootCmd.PersistentFlags().StringArray("set", nil, "Value of a variable of the deployment model as key=value, can be repeated")
+	rootCmd.PersistentFlags().StringSlice("profile", nil, "Profiles of the deployment model to apply in the given order, e.g. prod")
 	rootCmd.AddCommand(deployCmd)
 	rootCmd.AddCommand(addTypeCmd)
 	rootCmd.AddCommand(processCmd)
 	rootCmd.AddCommand(destroyCmd)
+	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(typesCmd)
+	typesCmd.AddCommand(typesShowCmd)
 
 	deployCmd.Flags().StringP("path", "p", "", "Path to the deployment YAML file")
 	deployCmd.MarkFlagRequired("path")
 	deployCmd.Flags().BoolP("measure", "m", false, "Measure the deployment performance")
-	deployCmd.Flags().Bool("no-tf", false, "Skip Terraform-related actions during deployment")
+	deployCmd.Flags().Bool("no-tf", false, "Skip Terraform-related actions during deployment, RabbitMQ hosts get definitions files instead")
+	deployCmd.Flags().Bool("rabbitmq-api", false, "Provision RabbitMQ hosts through their management API instead of Terraform")
+	deployCmd.Flags().StringP("name", "n", "default", "Name of the deployment")
+
+	destroyCmd.Flags().StringP("name", "n", "default", "Name of the deployment")
+
+	statusCmd.Flags().StringP("name", "n", "default", "Name of the deployment")
+	statusCmd.Flags().Bool("all", false, "List all deployments with their latest status")
+
+	historyCmd.Flags().StringP("name", "n", "default", "Name of the deployment")
+
+	generateCmd.Flags().StringP("path", "p", "", "Path to the deployment YAML file")
+	generateCmd.MarkFlagRequired("path")
+	generateCmd.Flags().StringP("out", "o", "", "Directory the generated models are written to")
+	generateCmd.MarkFlagRequired("out")
+	generateCmd.Flags().StringP("name", "n", "default", "Name of the deployment used for labels")
+	generateCmd.Flags().StringSliceP("targets", "t", nil, "Targets to generate (DockerCompose, Kafka, Kubernetes, Mosquitto, RabbitMQ, RabbitMQDefinitions), defaults to all targets the model needs")
+
+	planCmd.Flags().StringP("path", "p", "", "Path to the deployment YAML file")
+	planCmd.MarkFlagRequired("path")
+	planCmd.Flags().StringP("name", "n", "default", "Name of the deployment to compare with")
+
+	graphCmd.Flags().StringP("path", "p", "", "Path to the deployment YAML file")
+	graphCmd.MarkFlagRequired("path")
+	graphCmd.Flags().StringP("format", "f", GraphFormatDot, "Output format (dot, mermaid, plantuml)")
+	graphCmd.Flags().StringP("out", "o", "", "File the diagram is written to, defaults to stdout")
+
+	schemaCmd.Flags().StringP("out", "o", "", "File the schema is written to, defaults to stdout")
 
 	addTypeCmd.Flags().StringP("path", "p", "", "Path to the filter type YAML file")
 	addTypeCmd.MarkFlagRequired("path")
 
 	processCmd.Flags().StringP("content", "c", "", "Content of the deployment YAML file")
 	processCmd.MarkFlagRequired("content")
+	processCmd.Flags().Bool("expanded", false, "Also show the model with its variables and generators expanded")
 }
 
 func main() {
@@ -2,9 +2,12 @@ module eicoda
 
 go 1.22.2
 
+require (
+	github.com/spf13/cobra v1.8.1
+	gopkg.in/yaml.v2 v2.4.0
+)
+
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"eicoda/models"
+	"eicoda/utils"
+)
+
+//supported output formats of the graph export
+const (
+	GraphFormatDot      = "dot"
+	GraphFormatMermaid  = "mermaid"
+	GraphFormatPlantUML = "plantuml"
+)
+
+//stereotypes of the channels following the notation of the enterprise integration patterns
+const (
+	pointToPointChannel     = "Point-to-Point Channel"
+	publishSubscribeChannel = "Publish-Subscribe Channel"
+)
+
+var graphIDPattern = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+//represents a filter or channel in the exported graph
+type graphNode struct {
+	ID         string
+	Name       string
+	Stereotype string
+	Kind       string
+}
+
+//represents a host grouping the filters or channels that run on it
+type graphCluster struct {
+	ID    string
+	Name  string
+	Type  string
+	Nodes []graphNode
+}
+
+//represents the flow of messages between a filter and a channel
+type graphEdge struct {
+	From          string
+	To            string
+	Label         string
+	Bidirectional bool
+}
+
+//holds the format independent graph of a model
+type graph struct {
+	Clusters []graphCluster
+	Edges    []graphEdge
+}
+
+//renders the pipes-and-filters topology of a model in the given format
+func ExportGraph(model *models.Model, format string) (string, error) {
+	g := buildGraph(model)
+
+	switch strings.ToLower(format) {
+	case GraphFormatDot:
+		return g.renderDot(), nil
+	case GraphFormatMermaid:
+		return g.renderMermaid(), nil
+	case GraphFormatPlantUML:
+		return g.renderPlantUML(), nil
+	}
+	return "", fmt.Errorf("unknown graph format %s, supported formats are %s, %s and %s", format, GraphFormatDot, GraphFormatMermaid, GraphFormatPlantUML)
+}
+
+//groups filters and channels by host and derives the edges from the mappings, sorted by name for stable output
+func buildGraph(model *models.Model) *graph {
+	clusters := make(map[string]*graphCluster)
+	addNode := func(hostName string, node graphNode) {
+		cluster, exists := clusters[hostName]
+		if !exists {
+			cluster = &graphCluster{ID: graphID("host", hostName), Name: hostName}
+			if host := findModelHost(model, hostName); host != nil {
+				cluster.Type = host.Type
+			}
+			clusters[hostName] = cluster
+		}
+		cluster.Nodes = append(cluster.Nodes, node)
+	}
+
+	for _, queue := range utils.SortQueuesByName(model.Pipes.Queues) {
+		addNode(queue.Host, graphNode{ID: graphID("pipe", queue.Name), Name: queue.Name, Stereotype: pointToPointChannel, Kind: "queue"})
+	}
+	for _, topic := range utils.SortTopicsByName(model.Pipes.Topics) {
+		addNode(topic.Host, graphNode{ID: graphID("pipe", topic.Name), Name: topic.Name, Stereotype: publishSubscribeChannel, Kind: "topic"})
+	}
+	for _, filter := range utils.SortFiltersByName(model.Filters) {
+		addNode(filter.Host, graphNode{ID: graphID("filter", filter.Name), Name: filter.Name, Stereotype: filter.Type, Kind: "filter"})
+	}
+
+	g := &graph{}
+	var hostNames []string
+	for name := range clusters {
+		hostNames = append(hostNames, name)
+	}
+	sort.Strings(hostNames)
+	for _, name := range hostNames {
+		g.Clusters = append(g.Clusters, *clusters[name])
+	}
+
+	topology := BuildTopology(model)
+	for _, connection := range topology.Connections {
+		filterID := graphID("filter", connection.Filter)
+		pipeID := graphID("pipe", connection.Pipe)
+		edge := graphEdge{From: filterID, To: pipeID, Label: connection.RoutingKey}
+		switch connection.Direction {
+		case models.DirectionInput:
+			edge.From, edge.To = pipeID, filterID
+		case models.DirectionBoth:
+			edge.Bidirectional = true
+		}
+		g.Edges = append(g.Edges, edge)
+	}
+	//bindings route messages between channels without a filter, labeled with what they match
+	for _, binding := range model.Pipes.Bindings {
+		label := binding.RoutingKey
+		if len(binding.Arguments) > 0 {
+			var arguments []string
+			for name, value := range binding.Arguments {
+				arguments = append(arguments, name+"="+value)
+			}
+			sort.Strings(arguments)
+			label = strings.Join(arguments, ", ")
+		}
+		g.Edges = append(g.Edges, graphEdge{From: graphID("pipe", binding.Source), To: graphID("pipe", binding.Destination), Label: label})
+	}
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+
+	return g
+}
+
+//finds a host of the model by name regardless of whether it hosts pipes or filters
+func findModelHost(model *models.Model, name string) *models.Host {
+	if host := utils.FindHostByName(model.Hosts.FilterHosts, name); host != nil {
+		return host
+	}
+	return utils.FindHostByName(model.Hosts.PipeHosts, name)
+}
+
+//creates an identifier that is valid in all supported formats. Underscores are doubled and other invalid characters
+//are replaced by their hex code, so names like a-b and a_b keep distinct identifiers
+func graphID(prefix string, name string) string {
+	escaped := graphIDPattern.ReplaceAllStringFunc(name, func(char string) string {
+		if char == "_" {
+			return "__"
+		}
+		return fmt.Sprintf("_%x", char)
+	})
+	return prefix + "_" + escaped
+}
+
+func (c graphCluster) label() string {
+	if c.Type == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Type)
+}
+
+func (g *graph) renderDot() string {
+	var sb strings.Builder
+	sb.WriteString("digraph eicoda {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [fontname=\"Helvetica\"];\n")
+	for _, cluster := range g.Clusters {
+		sb.WriteString(fmt.Sprintf("  subgraph cluster_%s {\n", cluster.ID))
+		sb.WriteString(fmt.Sprintf("    label=%s;\n", dotQuote(cluster.label())))
+		sb.WriteString("    style=dashed;\n")
+		for _, node := range cluster.Nodes {
+			label := dotQuote(fmt.Sprintf("«%s»\n%s", node.Stereotype, node.Name))
+			switch node.Kind {
+			case "queue":
+				sb.WriteString(fmt.Sprintf("    %s [label=%s, shape=cylinder];\n", node.ID, label))
+			case "topic":
+				sb.WriteString(fmt.Sprintf("    %s [label=%s, shape=hexagon];\n", node.ID, label))
+			default:
+				sb.WriteString(fmt.Sprintf("    %s [label=%s, shape=box, style=rounded];\n", node.ID, label))
+			}
+		}
+		sb.WriteString("  }\n")
+	}
+	for _, edge := range g.Edges {
+		var attributes []string
+		if edge.Label != "" {
+			attributes = append(attributes, "label="+dotQuote(edge.Label))
+		}
+		if edge.Bidirectional {
+			attributes = append(attributes, "dir=both")
+		}
+		if len(attributes) > 0 {
+			sb.WriteString(fmt.Sprintf("  %s -> %s [%s];\n", edge.From, edge.To, strings.Join(attributes, ", ")))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s -> %s;\n", edge.From, edge.To))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+func (g *graph) renderMermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, cluster := range g.Clusters {
+		sb.WriteString(fmt.Sprintf("  subgraph %s[%s]\n", cluster.ID, mermaidQuote(cluster.label())))
+		for _, node := range cluster.Nodes {
+			label := mermaidQuote(fmt.Sprintf("«%s»<br/>%s", node.Stereotype, node.Name))
+			switch node.Kind {
+			case "queue":
+				sb.WriteString(fmt.Sprintf("    %s[(%s)]\n", node.ID, label))
+			case "topic":
+				sb.WriteString(fmt.Sprintf("    %s{{%s}}\n", node.ID, label))
+			default:
+				sb.WriteString(fmt.Sprintf("    %s(%s)\n", node.ID, label))
+			}
+		}
+		sb.WriteString("  end\n")
+	}
+	for _, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Bidirectional {
+			arrow = "<-->"
+		}
+		if edge.Label != "" {
+			sb.WriteString(fmt.Sprintf("  %s %s|%s| %s\n", edge.From, arrow, mermaidQuote(edge.Label), edge.To))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s %s %s\n", edge.From, arrow, edge.To))
+		}
+	}
+	return sb.String()
+}
+
+func mermaidQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "#quot;") + `"`
+}
+
+func (g *graph) renderPlantUML() string {
+	var sb strings.Builder
+	sb.WriteString("@startuml\n")
+	sb.WriteString("left to right direction\n")
+	for _, cluster := range g.Clusters {
+		sb.WriteString(fmt.Sprintf("node %s as %s {\n", plantUMLQuote(cluster.label()), cluster.ID))
+		for _, node := range cluster.Nodes {
+			switch node.Kind {
+			case "queue", "topic":
+				sb.WriteString(fmt.Sprintf("  queue %s as %s <<%s>>\n", plantUMLQuote(node.Name), node.ID, node.Stereotype))
+			default:
+				sb.WriteString(fmt.Sprintf("  component %s as %s <<%s>>\n", plantUMLQuote(node.Name), node.ID, node.Stereotype))
+			}
+		}
+		sb.WriteString("}\n")
+	}
+	for _, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Bidirectional {
+			arrow = "<-->"
+		}
+		if edge.Label != "" {
+			sb.WriteString(fmt.Sprintf("%s %s %s : %s\n", edge.From, arrow, edge.To, edge.Label))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s %s %s\n", edge.From, arrow, edge.To))
+		}
+	}
+	sb.WriteString("@enduml\n")
+	return sb.String()
+}
+
+func plantUMLQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "'") + `"`
+}
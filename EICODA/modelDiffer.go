@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"eicoda/models"
+)
+
+//actions of a model change
+const (
+	ChangeAdd    = "add"
+	ChangeRemove = "remove"
+	ChangeUpdate = "change"
+)
+
+//represents a single difference between the last applied and a new model
+type ModelChange struct {
+	Action  string
+	Kind    string
+	Name    string
+	Details []string
+}
+
+//host properties whose values are never printed in a plan
+var maskedProps = map[string]bool{
+	"password": true,
+}
+
+//compares two parsed models and returns the changes needed to get from the old to the new one
+func DiffModels(oldModel *models.Model, newModel *models.Model) []ModelChange {
+	if oldModel == nil {
+		oldModel = &models.Model{}
+	}
+
+	var changes []ModelChange
+	changes = append(changes, diffHosts("pipeHost", oldModel.Hosts.PipeHosts, newModel.Hosts.PipeHosts)...)
+	changes = append(changes, diffHosts("filterHost", oldModel.Hosts.FilterHosts, newModel.Hosts.FilterHosts)...)
+	changes = append(changes, diffQueues(oldModel.Pipes.Queues, newModel.Pipes.Queues)...)
+	changes = append(changes, diffTopics(oldModel.Pipes.Topics, newModel.Pipes.Topics)...)
+	changes = append(changes, diffBindings(oldModel.Pipes.Bindings, newModel.Pipes.Bindings)...)
+	changes = append(changes, diffFilters(oldModel.Filters, newModel.Filters)...)
+	return changes
+}
+
+func diffHosts(kind string, oldHosts []models.Host, newHosts []models.Host) []ModelChange {
+	oldMap := make(map[string]models.Host)
+	for _, host := range oldHosts {
+		oldMap[host.Name] = host
+	}
+	newMap := make(map[string]models.Host)
+	for _, host := range newHosts {
+		newMap[host.Name] = host
+	}
+
+	var changes []ModelChange
+	for _, name := range unionKeys(hostNames(oldHosts), hostNames(newHosts)) {
+		oldHost, inOld := oldMap[name]
+		newHost, inNew := newMap[name]
+		var details []string
+		if inOld && inNew {
+			diffField(&details, "type", oldHost.Type, newHost.Type)
+			diffProps(&details, "", oldHost.AdditionalProps, newHost.AdditionalProps)
+		}
+		changes = appendChange(changes, kind, name, inOld, inNew, details)
+	}
+	return changes
+}
+
+func diffQueues(oldQueues []models.Queue, newQueues []models.Queue) []ModelChange {
+	oldMap := make(map[string]models.Queue)
+	var oldNames []string
+	for _, queue := range oldQueues {
+		oldMap[queue.Name] = queue
+		oldNames = append(oldNames, queue.Name)
+	}
+	newMap := make(map[string]models.Queue)
+	var newNames []string
+	for _, queue := range newQueues {
+		newMap[queue.Name] = queue
+		newNames = append(newNames, queue.Name)
+	}
+
+	var changes []ModelChange
+	for _, name := range unionKeys(oldNames, newNames) {
+		oldQueue, inOld := oldMap[name]
+		newQueue, inNew := newMap[name]
+		var details []string
+		if inOld && inNew {
+			diffField(&details, "host", oldQueue.Host, newQueue.Host)
+			diffField(&details, "protocol", oldQueue.Protocol, newQueue.Protocol)
+			diffField(&details, "configs", oldQueue.Configs.String(), newQueue.Configs.String())
+			diffKafkaSettings(&details, oldQueue.KafkaSettings, newQueue.KafkaSettings)
+		}
+		changes = appendChange(changes, "queue", name, inOld, inNew, details)
+	}
+	return changes
+}
+
+func diffTopics(oldTopics []models.Topic, newTopics []models.Topic) []ModelChange {
+	oldMap := make(map[string]models.Topic)
+	var oldNames []string
+	for _, topic := range oldTopics {
+		oldMap[topic.Name] = topic
+		oldNames = append(oldNames, topic.Name)
+	}
+	newMap := make(map[string]models.Topic)
+	var newNames []string
+	for _, topic := range newTopics {
+		newMap[topic.Name] = topic
+		newNames = append(newNames, topic.Name)
+	}
+
+	var changes []ModelChange
+	for _, name := range unionKeys(oldNames, newNames) {
+		oldTopic, inOld := oldMap[name]
+		newTopic, inNew := newMap[name]
+		var details []string
+		if inOld && inNew {
+			diffField(&details, "host", oldTopic.Host, newTopic.Host)
+			diffField(&details, "protocol", oldTopic.Protocol, newTopic.Protocol)
+			diffField(&details, "exchangeType", oldTopic.Exchange(), newTopic.Exchange())
+			diffKafkaSettings(&details, oldTopic.KafkaSettings, newTopic.KafkaSettings)
+		}
+		changes = appendChange(changes, "topic", name, inOld, inNew, details)
+	}
+	return changes
+}
+
+//bindings are identified by their endpoints, routing key and arguments, so they are only added or removed
+func diffBindings(oldBindings []models.Binding, newBindings []models.Binding) []ModelChange {
+	oldKeys := make(map[string]bool)
+	var oldNames []string
+	for _, binding := range oldBindings {
+		oldKeys[binding.Key()] = true
+		oldNames = append(oldNames, binding.Key())
+	}
+	newKeys := make(map[string]bool)
+	var newNames []string
+	for _, binding := range newBindings {
+		newKeys[binding.Key()] = true
+		newNames = append(newNames, binding.Key())
+	}
+
+	var changes []ModelChange
+	for _, key := range unionKeys(oldNames, newNames) {
+		changes = appendChange(changes, "binding", key, oldKeys[key], newKeys[key], nil)
+	}
+	return changes
+}
+
+func diffFilters(oldFilters []models.Filter, newFilters []models.Filter) []ModelChange {
+	oldMap := make(map[string]models.Filter)
+	var oldNames []string
+	for _, filter := range oldFilters {
+		oldMap[filter.Name] = filter
+		oldNames = append(oldNames, filter.Name)
+	}
+	newMap := make(map[string]models.Filter)
+	var newNames []string
+	for _, filter := range newFilters {
+		newMap[filter.Name] = filter
+		newNames = append(newNames, filter.Name)
+	}
+
+	var changes []ModelChange
+	for _, name := range unionKeys(oldNames, newNames) {
+		oldFilter, inOld := oldMap[name]
+		newFilter, inNew := newMap[name]
+		var details []string
+		if inOld && inNew {
+			diffField(&details, "host", oldFilter.Host, newFilter.Host)
+			diffField(&details, "type", oldFilter.Type, newFilter.Type)
+			diffField(&details, "artifact", oldFilter.Artifact, newFilter.Artifact)
+			diffField(&details, "replicas", strconv.Itoa(oldFilter.ReplicaCount()), strconv.Itoa(newFilter.ReplicaCount()))
+			diffField(&details, "mappings", joinMappings(oldFilter.Mappings), joinMappings(newFilter.Mappings))
+			diffProps(&details, "config ", oldFilter.AdditionalProps, newFilter.AdditionalProps)
+		}
+		changes = appendChange(changes, "filter", name, inOld, inNew, details)
+	}
+	return changes
+}
+
+//appends an add, remove or change entry depending on where the object exists
+func appendChange(changes []ModelChange, kind string, name string, inOld bool, inNew bool, details []string) []ModelChange {
+	switch {
+	case !inOld && inNew:
+		return append(changes, ModelChange{Action: ChangeAdd, Kind: kind, Name: name})
+	case inOld && !inNew:
+		return append(changes, ModelChange{Action: ChangeRemove, Kind: kind, Name: name})
+	case len(details) > 0:
+		return append(changes, ModelChange{Action: ChangeUpdate, Kind: kind, Name: name, Details: details})
+	}
+	return changes
+}
+
+func diffField(details *[]string, field string, oldValue string, newValue string) {
+	if oldValue != newValue {
+		*details = append(*details, fmt.Sprintf("%s: %q -> %q", field, oldValue, newValue))
+	}
+}
+
+//compares the settings of the kafka topic of a pipe
+func diffKafkaSettings(details *[]string, oldSettings models.KafkaSettings, newSettings models.KafkaSettings) {
+	diffField(details, "partitions", strconv.Itoa(oldSettings.Partitions), strconv.Itoa(newSettings.Partitions))
+	diffField(details, "replicationFactor", strconv.Itoa(oldSettings.ReplicationFactor), strconv.Itoa(newSettings.ReplicationFactor))
+	diffField(details, "retention", oldSettings.Retention, newSettings.Retention)
+}
+
+//compares key value properties, masking sensitive values
+func diffProps(details *[]string, prefix string, oldProps map[string]string, newProps map[string]string) {
+	var oldKeys, newKeys []string
+	for key := range oldProps {
+		oldKeys = append(oldKeys, key)
+	}
+	for key := range newProps {
+		newKeys = append(newKeys, key)
+	}
+
+	for _, key := range unionKeys(oldKeys, newKeys) {
+		oldValue, inOld := oldProps[key]
+		newValue, inNew := newProps[key]
+		if inOld && inNew && oldValue == newValue {
+			continue
+		}
+		if maskedProps[key] {
+			*details = append(*details, fmt.Sprintf("%s%s: (sensitive value changed)", prefix, key))
+			continue
+		}
+		switch {
+		case !inOld:
+			*details = append(*details, fmt.Sprintf("%s%s: added %q", prefix, key, newValue))
+		case !inNew:
+			*details = append(*details, fmt.Sprintf("%s%s: removed %q", prefix, key, oldValue))
+		default:
+			*details = append(*details, fmt.Sprintf("%s%s: %q -> %q", prefix, key, oldValue, newValue))
+		}
+	}
+}
+
+func joinMappings(mappings []models.Mapping) string {
+	var values []string
+	for _, mapping := range mappings {
+		values = append(values, mapping.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+func hostNames(hosts []models.Host) []string {
+	var names []string
+	for _, host := range hosts {
+		names = append(names, host.Name)
+	}
+	return names
+}
+
+//returns the sorted union of both name lists without duplicates
+func unionKeys(a []string, b []string) []string {
+	set := make(map[string]bool)
+	for _, key := range a {
+		set[key] = true
+	}
+	for _, key := range b {
+		set[key] = true
+	}
+
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"eicoda/models"
+)
+
+//holds the definitions of one kind that an imported file contributes
+type importedDefinitions[T any] struct {
+	Source string
+	Items  []T
+}
+
+//resolves the imports of a model recursively and merges the imported definitions into it.
+//local definitions override imported ones with the same name, identical definitions of several imports are
+//kept once and differing ones are a conflict. modelPath is the file of the model, stack the files being imported
+func (parser *ModelParser) resolveImports(model *models.Model, modelPath string, stack []string) error {
+	absolutePath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", modelPath, err)
+	}
+	for _, importing := range stack {
+		if importing == absolutePath {
+			return fmt.Errorf("import cycle: %s", strings.Join(append(stack, absolutePath), " -> "))
+		}
+	}
+	stack = append(stack, absolutePath)
+
+	paths, err := importPaths(model.Imports, filepath.Dir(modelPath), absolutePath)
+	if err != nil {
+		return err
+	}
+	model.Imports = nil
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var imported []*models.Model
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read import %s: %w", path, err)
+		}
+		data, err = expandModelData(data, parser.variables)
+		if err != nil {
+			return fmt.Errorf("failed to expand import %s: %w", path, err)
+		}
+		var importedModel models.Model
+		err = yaml.Unmarshal(data, &importedModel)
+		if err != nil {
+			return fmt.Errorf("failed to parse import %s: %w", path, err)
+		}
+		err = parser.resolveImports(&importedModel, path, stack)
+		if err != nil {
+			return err
+		}
+		imported = append(imported, &importedModel)
+	}
+
+	return mergeImports(model, paths, imported)
+}
+
+//expands the import patterns relative to the directory of the importing model, a model never imports itself
+func importPaths(patterns []string, baseDir string, modelPath string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid import pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("import %s matches no files", pattern)
+		}
+
+		for _, match := range matches {
+			absolutePath, err := filepath.Abs(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve path %s: %w", match, err)
+			}
+			if absolutePath == modelPath || seen[absolutePath] {
+				continue
+			}
+			seen[absolutePath] = true
+			paths = append(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+//merges the definitions of the imported models into the model
+func mergeImports(model *models.Model, paths []string, imported []*models.Model) error {
+	var err error
+	queues := make([]importedDefinitions[models.Queue], len(imported))
+	topics := make([]importedDefinitions[models.Topic], len(imported))
+	bindings := make([]importedDefinitions[models.Binding], len(imported))
+	filters := make([]importedDefinitions[models.Filter], len(imported))
+	pipeHosts := make([]importedDefinitions[models.Host], len(imported))
+	filterHosts := make([]importedDefinitions[models.Host], len(imported))
+	filterTypes := make([]importedDefinitions[models.FilterType], len(imported))
+	artifacts := make([]importedDefinitions[models.DeploymentArtifact], len(imported))
+	for i, importedModel := range imported {
+		queues[i] = importedDefinitions[models.Queue]{paths[i], importedModel.Pipes.Queues}
+		topics[i] = importedDefinitions[models.Topic]{paths[i], importedModel.Pipes.Topics}
+		bindings[i] = importedDefinitions[models.Binding]{paths[i], importedModel.Pipes.Bindings}
+		filters[i] = importedDefinitions[models.Filter]{paths[i], importedModel.Filters}
+		pipeHosts[i] = importedDefinitions[models.Host]{paths[i], importedModel.Hosts.PipeHosts}
+		filterHosts[i] = importedDefinitions[models.Host]{paths[i], importedModel.Hosts.FilterHosts}
+		filterTypes[i] = importedDefinitions[models.FilterType]{paths[i], importedModel.FilterTypes}
+		artifacts[i] = importedDefinitions[models.DeploymentArtifact]{paths[i], importedModel.DeploymentArtifacts}
+	}
+
+	model.Pipes.Queues, err = mergeDefinitions("queue", model.Pipes.Queues, queues, func(q models.Queue) string { return q.Name })
+	if err != nil {
+		return err
+	}
+	model.Pipes.Topics, err = mergeDefinitions("topic", model.Pipes.Topics, topics, func(t models.Topic) string { return t.Name })
+	if err != nil {
+		return err
+	}
+	//a binding is identified by all of its fields, so the same binding in several models is only added once
+	model.Pipes.Bindings, err = mergeDefinitions("binding", model.Pipes.Bindings, bindings, func(b models.Binding) string { return b.Key() })
+	if err != nil {
+		return err
+	}
+	model.Filters, err = mergeDefinitions("filter", model.Filters, filters, func(f models.Filter) string { return f.Name })
+	if err != nil {
+		return err
+	}
+	model.Hosts.PipeHosts, err = mergeDefinitions("pipeHost", model.Hosts.PipeHosts, pipeHosts, func(h models.Host) string { return h.Name })
+	if err != nil {
+		return err
+	}
+	model.Hosts.FilterHosts, err = mergeDefinitions("filterHost", model.Hosts.FilterHosts, filterHosts, func(h models.Host) string { return h.Name })
+	if err != nil {
+		return err
+	}
+	model.FilterTypes, err = mergeDefinitions("filter type", model.FilterTypes, filterTypes, func(ft models.FilterType) string { return ft.Name })
+	if err != nil {
+		return err
+	}
+	model.DeploymentArtifacts, err = mergeDefinitions("deployment artifact", model.DeploymentArtifacts, artifacts, func(da models.DeploymentArtifact) string { return da.Name })
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//merges the definitions of one kind, imported definitions come first in the order of the imports followed by the local ones
+func mergeDefinitions[T any](kind string, local []T, imports []importedDefinitions[T], nameOf func(T) string) ([]T, error) {
+	localNames := make(map[string]bool)
+	for _, item := range local {
+		localNames[nameOf(item)] = true
+	}
+
+	var result []T
+	sources := make(map[string]string)
+	definitions := make(map[string]T)
+	for _, imported := range imports {
+		for _, item := range imported.Items {
+			name := nameOf(item)
+			if localNames[name] {
+				continue
+			}
+			if existing, exists := definitions[name]; exists {
+				if !reflect.DeepEqual(existing, item) {
+					return nil, fmt.Errorf("conflicting definitions of %s %s in %s and %s", kind, name, sources[name], imported.Source)
+				}
+				continue
+			}
+			sources[name] = imported.Source
+			definitions[name] = item
+			result = append(result, item)
+		}
+	}
+
+	return append(result, local...), nil
+}
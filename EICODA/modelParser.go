@@ -1,20 +1,35 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 
 	"gopkg.in/yaml.v2"
 	"eicoda/models"
+	"eicoda/transformators"
 	"eicoda/utils"
 )
 
+//protocols pipes can use, the pipe host types declare which of them they support
+var pipeProtocols = []string{"amqp", "mqtt", "kafka"}
+
+//SASL mechanisms of Kafka hosts that authenticate with username and password
+var kafkaSaslMechanisms = []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"}
+
+//characters kafka allows in topic names, which are at most 249 characters long
+var kafkaTopicNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
 type ModelParser struct {
 	hostTypes models.HostTypes
+	variables map[string]interface{}
+	profiles  []string
 }
 
 func NewModelParser() *ModelParser {
@@ -39,7 +54,7 @@ func (parser *ModelParser) loadHostTypes() {
 	}
 
 	parser.hostTypes = rawHostTypes.Hosts
-	fmt.Println("Loaded host types.")
+	fmt.Fprintln(os.Stderr, "Loaded host types.")
 }
 
 func (parser *ModelParser) Parse(path string) (*models.Model, error) {
@@ -48,94 +63,186 @@ func (parser *ModelParser) Parse(path string) (*models.Model, error) {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 
-	return parser.parseData(data)
+	return parser.parseData(data, path)
 }
 
+//parses a model that is not stored in a file, file backed configs cannot be resolved and are not checked
 func (parser *ModelParser) ParseFromString(content string) (*models.Model, error) {
 	data := []byte(content)
-	return parser.parseData(data)
+	return parser.parseData(data, "")
+}
+
+//sets the variable values given on the command line or in a vars file, they take precedence over the defaults of a model
+func (parser *ModelParser) SetVariables(values map[string]interface{}) {
+	parser.variables = values
+}
+
+//selects the profiles that are applied to every parsed model in the given order
+func (parser *ModelParser) SetProfiles(names []string) {
+	parser.profiles = names
+}
+
+//returns the selected profiles
+func (parser *ModelParser) Profiles() []string {
+	return parser.profiles
 }
 
-func (parser *ModelParser) parseData(data []byte) (*models.Model, error) {
+//substitutes the variables and expands the generators of a model without parsing it
+func (parser *ModelParser) Expand(content string) (string, error) {
+	data, err := expandModelData([]byte(content), parser.variables)
+	if err != nil {
+		return "", fmt.Errorf("expanding model failed: %w", err)
+	}
+	return string(data), nil
+}
+
+func (parser *ModelParser) parseData(data []byte, modelPath string) (*models.Model, error) {
+	data, err := expandModelData(data, parser.variables)
+	if err != nil {
+		return nil, fmt.Errorf("expanding model failed: %w", err)
+	}
+
 	var model models.Model
-	err := yaml.Unmarshal(data, &model)
+	err = yaml.Unmarshal(data, &model)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
 	}
 
-	typesFilePath := filepath.Join("repositoryControllers", "types.yaml")
-	mergedTypesFilePath := filepath.Join("repositoryControllers", "mergedTypes.yaml")
-	var typesData []byte
-
-	if _, err := os.Stat(mergedTypesFilePath); err == nil {
-		typesData, err = ioutil.ReadFile(mergedTypesFilePath)
+	//imports are resolved relative to the model file before anything else is merged or checked
+	baseDir := ""
+	if modelPath != "" {
+		baseDir = filepath.Dir(modelPath)
+		err = parser.resolveImports(&model, modelPath, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read mergedTypes.yaml: %w", err)
-		}
-	} else {
-		typesData, err = ioutil.ReadFile(typesFilePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read types.yaml: %w", err)
+			return nil, fmt.Errorf("resolving imports failed: %w", err)
 		}
+	} else if len(model.Imports) > 0 {
+		return nil, fmt.Errorf("imports are only supported for models read from a file")
 	}
 
-	var combinedTypes models.CombinedTypes
-	err = yaml.Unmarshal(typesData, &combinedTypes)
+	//profiles patch the model itself, so they are applied before the persisted types and hosts are merged
+	err = model.ApplyProfiles(parser.profiles)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse types file: %w", err)
+		return nil, fmt.Errorf("applying profiles failed: %w", err)
+	}
+	for _, name := range parser.profiles {
+		fmt.Fprintf(os.Stderr, "Applied profile %s.\n", name)
 	}
 
-	//resolves inheritance structure
-	parser.resolveInheritance(&combinedTypes)
+	combinedTypes, err := parser.LoadTypes()
+	if err != nil {
+		return nil, err
+	}
 
-	err = parser.mergeModels(&model, &combinedTypes)
+	err = parser.mergeModels(&model, combinedTypes)
 	if err != nil {
 		return nil, err
 	}
 
+	//resolves inheritance structure of the persisted and the model's own filter types
+	model.FilterTypes, err = models.ResolveFilterTypes(model.FilterTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter types: %w", err)
+	}
+
 	err = parser.performChecks(&model)
 	if err != nil {
 		return nil, fmt.Errorf("parsing model failed: %w", err)
 	}
 
-	err = parser.applyFilterTypeArtifacts(&model, &combinedTypes)
+	err = parser.applyFilterTypeArtifacts(&model)
 	if err != nil {
 		return nil, err
 	}
 
-	err = parser.checkFilterMappings(&model, &combinedTypes)
+	err = parser.checkFilterMappings(&model, combinedTypes)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("Parsed and merged model successfully.")
+	if baseDir != "" {
+		err = parser.checkFileConfigs(&model, baseDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parser.checkSecretReferences(&model, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = parser.checkTopology(&model)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(os.Stderr, "Parsed and merged model successfully.")
 	return &model, nil
 }
 
+//loads the persisted filter types, deployment artifacts and hosts from mergedTypes.yaml or types.yaml
+func (parser *ModelParser) LoadTypes() (*models.CombinedTypes, error) {
+	typesFilePath := filepath.Join("repositoryControllers", "types.yaml")
+	mergedTypesFilePath := filepath.Join("repositoryControllers", "mergedTypes.yaml")
+	var typesData []byte
+
+	if _, err := os.Stat(mergedTypesFilePath); err == nil {
+		typesData, err = ioutil.ReadFile(mergedTypesFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mergedTypes.yaml: %w", err)
+		}
+	} else {
+		typesData, err = ioutil.ReadFile(typesFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read types.yaml: %w", err)
+		}
+	}
+
+	var combinedTypes models.CombinedTypes
+	err := yaml.Unmarshal(typesData, &combinedTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse types file: %w", err)
+	}
+
+	return &combinedTypes, nil
+}
+
+//returns the host types loaded from hostTypes.yaml
+func (parser *ModelParser) HostTypes() models.HostTypes {
+	return parser.hostTypes
+}
+
 func (parser *ModelParser) mergeModels(model *models.Model, combinedTypes *models.CombinedTypes) error {
 	
-	//merge filtertypes (defined and persisted ones)
-	filterTypeNames := make(map[string]bool)
+	//merge filtertypes (defined and persisted ones), a definition identical to the persisted one is kept once
+	filterTypes := make(map[string]models.FilterType)
 	for _, ft := range model.FilterTypes {
-		filterTypeNames[ft.Name] = true
+		filterTypes[ft.Name] = ft
 	}
 	for _, ft := range combinedTypes.FilterTypes {
 		if ft.Name == "" {
 			continue
 		}
-		if filterTypeNames[ft.Name] {
+		if existing, exists := filterTypes[ft.Name]; exists {
+			if reflect.DeepEqual(existing, ft) {
+				continue
+			}
 			return fmt.Errorf("duplicate filter type name found: %s", ft.Name)
 		}
 		model.FilterTypes = append(model.FilterTypes, ft)
 	}
 
 	// merge deploymentartifacts (defined and persisted ones)
-	deploymentArtifactNames := make(map[string]bool)
+	deploymentArtifacts := make(map[string]models.DeploymentArtifact)
 	for _, da := range model.DeploymentArtifacts {
-		deploymentArtifactNames[da.Name] = true
+		deploymentArtifacts[da.Name] = da
 	}
 	for _, da := range combinedTypes.DeploymentArtifacts {
-		if deploymentArtifactNames[da.Name] {
+		if existing, exists := deploymentArtifacts[da.Name]; exists {
+			if reflect.DeepEqual(existing, da) {
+				continue
+			}
 			return fmt.Errorf("duplicate deployment artifact name found: %s", da.Name)
 		}
 		model.DeploymentArtifacts = append(model.DeploymentArtifacts, da)
@@ -150,74 +257,40 @@ func (parser *ModelParser) mergeModels(model *models.Model, combinedTypes *model
 	return nil
 }
 
+//adds the hosts of the type repository, a host the model defines under the same name and ID overrides the repository
+//host. A host with the same name but another ID is reported, as it would silently replace an unrelated host
 func (parser *ModelParser) mergeHosts(model *models.Model, combinedHosts *models.Hosts) error {
-	hostIDs := make(map[string]bool)
-	for _, host := range model.Hosts.PipeHosts {
-		hostIDs[host.ID] = true
-	}
-	for _, host := range combinedHosts.PipeHosts {
-		if hostIDs[host.ID] {
-			return fmt.Errorf("duplicate pipeHost ID found: %s", host.ID)
-		}
-		model.Hosts.PipeHosts = append(model.Hosts.PipeHosts, host)
-	}
-
-	for _, host := range model.Hosts.FilterHosts {
-		hostIDs[host.ID] = true
-	}
-	for _, host := range combinedHosts.FilterHosts {
-		if hostIDs[host.ID] {
-			return fmt.Errorf("duplicate filterHost ID found: %s", host.ID)
-		}
-		model.Hosts.FilterHosts = append(model.Hosts.FilterHosts, host)
-	}
-
-	return nil
-}
-
-//resolves the inheritance hierarchy for filter types
-func (parser *ModelParser) resolveInheritance(combinedTypes *models.CombinedTypes) {
-	filterTypeMap := make(map[string]*models.FilterType)
-	for i, ft := range combinedTypes.FilterTypes {
-		filterTypeMap[ft.Name] = &combinedTypes.FilterTypes[i]
-	}
-
-	for i := range combinedTypes.FilterTypes {
-		parser.inheritFilterTypeProperties(filterTypeMap, &combinedTypes.FilterTypes[i])
+	var err error
+	model.Hosts.PipeHosts, err = mergeHostList("pipeHost", model.Hosts.PipeHosts, combinedHosts.PipeHosts)
+	if err != nil {
+		return err
 	}
+	model.Hosts.FilterHosts, err = mergeHostList("filterHost", model.Hosts.FilterHosts, combinedHosts.FilterHosts)
+	return err
 }
 
-func (parser *ModelParser) inheritFilterTypeProperties(filterTypeMap map[string]*models.FilterType, ft *models.FilterType) {
-	if ft.DerivedFrom == "" {
-		return
-	}
-
-	parent, exists := filterTypeMap[ft.DerivedFrom]
-	if !exists {
-		fmt.Printf("Parent filter type %s not found for filter type %s\n", ft.DerivedFrom, ft.Name)
-		return
-	}
-
-	parser.inheritFilterTypeProperties(filterTypeMap, parent)
-
-	if parent.Configs != nil {
-		for _, parentConfig := range parent.Configs {
-			exists := false
-			for _, config := range ft.Configs {
-				if config.Name == parentConfig.Name {
-					exists = true
-					break
-				}
-			}
-			if !exists {
-				ft.Configs = append(ft.Configs, parentConfig)
+//merges the repository hosts of one kind into the model hosts of the same kind, pipe hosts and filter hosts are
+//distinct, so a filter host may share its name or ID with a pipe host
+func mergeHostList(kind string, modelHosts []models.Host, repositoryHosts []models.Host) ([]models.Host, error) {
+	hosts := make(map[string]models.Host)
+	names := make(map[string]string)
+	for _, host := range modelHosts {
+		hosts[host.ID] = host
+		names[host.Name] = host.ID
+	}
+	for _, host := range repositoryHosts {
+		if existing, exists := hosts[host.ID]; exists {
+			if existing.Name == host.Name {
+				continue
 			}
+			return nil, fmt.Errorf("duplicate %s ID found: %s", kind, host.ID)
 		}
+		if id, exists := names[host.Name]; exists {
+			return nil, fmt.Errorf("duplicate %s name found: %s is defined with ID %s in the model and with ID %s in the repository", kind, host.Name, id, host.ID)
+		}
+		modelHosts = append(modelHosts, host)
 	}
-
-	if ft.Artifact == "" {
-		ft.Artifact = parent.Artifact
-	}
+	return modelHosts, nil
 }
 
 //checks if filters have the required properties based on their type
@@ -228,6 +301,10 @@ func (parser *ModelParser) checkFilterTypeEnforcements(model *models.Model) erro
 	}
 
 	for _, filter := range model.Filters {
+		//a filter without instances would silently be deployed once, disabled filters are removed with enabled: false
+		if filter.ReplicaCount() < 1 {
+			return fmt.Errorf("filter %s has an invalid number of replicas: %d, at least 1 is required", filter.Name, filter.ReplicaCount())
+		}
 		if filter.Type == "Custom" {
 			continue
 		}
@@ -236,6 +313,18 @@ func (parser *ModelParser) checkFilterTypeEnforcements(model *models.Model) erro
 		if !exists {
 			return fmt.Errorf("filter type %s not found for filter %s", filter.Type, filter.Name)
 		}
+		if filterType.Abstract {
+			return fmt.Errorf("filter %s cannot use abstract filter type %s", filter.Name, filter.Type)
+		}
+		if filterType.Stateful && filter.ReplicaCount() > 1 {
+			return fmt.Errorf("filter %s cannot have %d replicas, filter type %s is stateful and not safe to scale", filter.Name, filter.ReplicaCount(), filter.Type)
+		}
+
+		for _, config := range filterType.Configs {
+			if err := config.Check(); err != nil {
+				return fmt.Errorf("filter type %s is invalid: %w", filterType.Name, err)
+			}
+		}
 
 		if filter.AdditionalProps == nil {
 			filter.AdditionalProps = make(map[string]string)
@@ -264,15 +353,21 @@ func (parser *ModelParser) checkFilterTypeEnforcements(model *models.Model) erro
 				return fmt.Errorf("filter %s of type %s has an invalid property: %s", filter.Name, filter.Type, prop)
 			}
 		}
+
+		for _, config := range filterType.Configs {
+			if _, err := config.NormalizeValue(filter.AdditionalProps[config.Name]); err != nil {
+				return fmt.Errorf("filter %s of type %s has an invalid value for property %s: %w", filter.Name, filter.Type, config.Name, err)
+			}
+		}
 	}
 
 	return nil
 }
 
 //applies artifacts and mappings from filter types if not set in the filter
-func (parser *ModelParser) applyFilterTypeArtifacts(model *models.Model, combinedTypes *models.CombinedTypes) error {
+func (parser *ModelParser) applyFilterTypeArtifacts(model *models.Model) error {
 	filterTypeMap := make(map[string]models.FilterType)
-	for _, ft := range combinedTypes.FilterTypes {
+	for _, ft := range model.FilterTypes {
 		filterTypeMap[ft.Name] = ft
 	}
 
@@ -303,6 +398,11 @@ func (parser *ModelParser) performChecks(model *models.Model) error {
 		return err
 	}
 
+	err = parser.checkForDuplicateHostNames(model)
+	if err != nil {
+		return err
+	}
+
 	err = parser.checkQueueHosts(model)
 	if err != nil {
 		return err
@@ -313,6 +413,21 @@ func (parser *ModelParser) performChecks(model *models.Model) error {
 		return err
 	}
 
+	err = parser.checkQueueConfigs(model)
+	if err != nil {
+		return err
+	}
+
+	err = parser.checkKafkaSettings(model)
+	if err != nil {
+		return err
+	}
+
+	err = parser.checkBindings(model)
+	if err != nil {
+		return err
+	}
+
 	err = parser.checkFilterHosts(model)
 	if err != nil {
 		return err
@@ -373,6 +488,38 @@ func (parser *ModelParser) checkForDuplicateIDs(model *models.Model) error {
 	return nil
 }
 
+//checks that the names of the pipeHosts and of the filterHosts are unique, since pipes and filters reference their hosts by name.
+//the names of pipeHosts also have to stay unique in the identifiers the target models derive from them
+func (parser *ModelParser) checkForDuplicateHostNames(model *models.Model) error {
+	//queues and topics reference pipe hosts and filters reference filter hosts, so the names only have to be unique per kind
+	pipeHostNames := make(map[string]bool)
+	for _, host := range model.Hosts.PipeHosts {
+		if pipeHostNames[host.Name] {
+			return fmt.Errorf("duplicate pipeHost name found: %s", host.Name)
+		}
+		pipeHostNames[host.Name] = true
+	}
+	filterHostNames := make(map[string]bool)
+	for _, host := range model.Hosts.FilterHosts {
+		if filterHostNames[host.Name] {
+			return fmt.Errorf("duplicate filterHost name found: %s", host.Name)
+		}
+		filterHostNames[host.Name] = true
+	}
+
+	identifiers := make(map[string]string)
+	for i := range model.Hosts.PipeHosts {
+		host := &model.Hosts.PipeHosts[i]
+		for _, identifier := range transformators.HostIdentifiers(host) {
+			if other, exists := identifiers[identifier]; exists && other != host.Name {
+				return fmt.Errorf("pipeHosts %s and %s cannot be told apart, both names map to %s", other, host.Name, identifier)
+			}
+			identifiers[identifier] = host.Name
+		}
+	}
+	return nil
+}
+
 //checks if the host field of each queue refers to a defined name of a pipeHost
 func (parser *ModelParser) checkQueueHosts(model *models.Model) error {
 	pipeHosts := make(map[string]bool)
@@ -395,21 +542,206 @@ func (parser *ModelParser) checkQueueHosts(model *models.Model) error {
 	return nil
 }
 
-//checks if the protocol is either amqp or mqtt
+//checks if the protocol is amqp, mqtt or kafka and supported by the type of the pipe host, e.g. Mosquitto only speaks mqtt
 func (parser *ModelParser) checkQueueProtocols(model *models.Model) error {
 	for _, queue := range model.Pipes.Queues {
-		if queue.Protocol != "amqp" && queue.Protocol != "mqtt" {
+		if !contains(pipeProtocols, queue.Protocol) {
 			return fmt.Errorf("queue protocol %s is invalid for queue %s", queue.Protocol, queue.Name)
 		}
+		if host, supported := parser.supportsProtocol(model, queue.Host, queue.Protocol); !supported {
+			return fmt.Errorf("queue %s uses protocol %s, which pipeHost %s of type %s does not support", queue.Name, queue.Protocol, host.Name, host.Type)
+		}
 	}
 	for _, topic := range model.Pipes.Topics {
-		if topic.Protocol != "amqp" && topic.Protocol != "mqtt" {
+		if !contains(pipeProtocols, topic.Protocol) {
 			return fmt.Errorf("topic protocol %s is invalid for topic %s", topic.Protocol, topic.Name)
 		}
+		if host, supported := parser.supportsProtocol(model, topic.Host, topic.Protocol); !supported {
+			return fmt.Errorf("topic %s uses protocol %s, which pipeHost %s of type %s does not support", topic.Name, topic.Protocol, host.Name, host.Type)
+		}
 	}
 	return nil
 }
 
+//checks the configs of queues, which only RabbitMQ hosts support
+func (parser *ModelParser) checkQueueConfigs(model *models.Model) error {
+	for _, queue := range model.Pipes.Queues {
+		if queue.Configs == nil {
+			continue
+		}
+		host := utils.FindHostByName(model.Hosts.PipeHosts, queue.Host)
+		if host == nil || host.Type != "RabbitMQ" {
+			return fmt.Errorf("queue %s sets configs, which only queues of RabbitMQ hosts support", queue.Name)
+		}
+		if err := queue.Configs.Validate(); err != nil {
+			return fmt.Errorf("invalid configs of queue %s: %w", queue.Name, err)
+		}
+		//the queue of an MQTT pipe is bound to the exchange of the MQTT plugin before any filter connects, MQTT clients
+		//cannot declare it themselves
+		if queue.Protocol == "mqtt" && queue.Configs.Exclusive {
+			return fmt.Errorf("queue %s uses mqtt and cannot be exclusive", queue.Name)
+		}
+	}
+	return nil
+}
+
+//checks the kafka settings of pipes and the security settings of Kafka hosts
+func (parser *ModelParser) checkKafkaSettings(model *models.Model) error {
+	for _, host := range model.Hosts.PipeHosts {
+		if host.Type != "Kafka" {
+			continue
+		}
+		securityProtocol := host.AdditionalProps["security_protocol"]
+		switch securityProtocol {
+		case "", "PLAINTEXT", "SSL":
+		case "SASL_PLAINTEXT", "SASL_SSL":
+			if !contains(kafkaSaslMechanisms, host.AdditionalProps["sasl_mechanism"]) {
+				return fmt.Errorf("pipeHost %s uses %s and needs a sasl_mechanism of %s", host.Name, securityProtocol, strings.Join(kafkaSaslMechanisms, ", "))
+			}
+			if host.AdditionalProps["username"] == "" || host.AdditionalProps["password"] == "" {
+				return fmt.Errorf("pipeHost %s uses %s and needs a username and password", host.Name, securityProtocol)
+			}
+		default:
+			return fmt.Errorf("pipeHost %s has invalid security_protocol %s, expected PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL", host.Name, securityProtocol)
+		}
+	}
+
+	check := func(kind string, name string, hostName string, settings models.KafkaSettings) error {
+		if !settings.IsSet() {
+			return nil
+		}
+		host := utils.FindHostByName(model.Hosts.PipeHosts, hostName)
+		if host == nil || host.Type != "Kafka" {
+			return fmt.Errorf("%s %s sets partitions, replicationFactor or retention, which only pipes of Kafka hosts support", kind, name)
+		}
+		if err := settings.Validate(); err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		return nil
+	}
+	for _, queue := range model.Pipes.Queues {
+		if err := check("queue", queue.Name, queue.Host, queue.KafkaSettings); err != nil {
+			return err
+		}
+	}
+	for _, topic := range model.Pipes.Topics {
+		if err := check("topic", topic.Name, topic.Host, topic.KafkaSettings); err != nil {
+			return err
+		}
+	}
+
+	//queues and topics of Kafka hosts become KafkaTopic resources named after the sanitized pipe name, so two pipes
+	//must not end up with the same resource name in a namespace
+	resourceNames := make(map[string]string)
+	checkName := func(kind string, name string, hostName string) error {
+		host := utils.FindHostByName(model.Hosts.PipeHosts, hostName)
+		if host == nil || host.Type != "Kafka" {
+			return nil
+		}
+		if !kafkaTopicNamePattern.MatchString(name) {
+			return fmt.Errorf("%s %s has an invalid kafka topic name, only up to 249 letters, digits, '.', '_' and '-' are allowed", kind, name)
+		}
+		resourceName := host.AdditionalProps["namespace"] + "/" + utils.SanitizeName(name)
+		if other, exists := resourceNames[resourceName]; exists {
+			return fmt.Errorf("%s %s and pipe %s map to the same KafkaTopic name %s", kind, name, other, utils.SanitizeName(name))
+		}
+		resourceNames[resourceName] = name
+		return nil
+	}
+	for _, queue := range model.Pipes.Queues {
+		if err := checkName("queue", queue.Name, queue.Host); err != nil {
+			return err
+		}
+	}
+	for _, topic := range model.Pipes.Topics {
+		if err := checkName("topic", topic.Name, topic.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//checks the exchange types of topics and that bindings connect an exchange of a RabbitMQ host to a queue or another
+//exchange of the same host and vhost, with a routing key or arguments that fit the type of the source exchange
+func (parser *ModelParser) checkBindings(model *models.Model) error {
+	isExchange := func(topic *models.Topic) bool {
+		if topic == nil || topic.Protocol != "amqp" {
+			return false
+		}
+		host := utils.FindHostByName(model.Hosts.PipeHosts, topic.Host)
+		return host != nil && host.Type == "RabbitMQ"
+	}
+
+	for _, topic := range model.Pipes.Topics {
+		if topic.ExchangeType == "" {
+			continue
+		}
+		if !contains(models.ExchangeTypes, topic.ExchangeType) {
+			return fmt.Errorf("invalid exchangeType %s of topic %s, expected one of %s", topic.ExchangeType, topic.Name, strings.Join(models.ExchangeTypes, ", "))
+		}
+		if !isExchange(&topic) {
+			return fmt.Errorf("topic %s sets an exchangeType, which only amqp topics of RabbitMQ hosts support", topic.Name)
+		}
+	}
+
+	for _, binding := range model.Pipes.Bindings {
+		source := utils.FindTopicByName(model.Pipes.Topics, binding.Source)
+		if source == nil {
+			return fmt.Errorf("source %s of binding %s is not defined as a topic", binding.Source, binding.Key())
+		}
+		if !isExchange(source) {
+			return fmt.Errorf("source %s of binding %s is not an amqp topic of a RabbitMQ host", binding.Source, binding.Key())
+		}
+		//exchanges live in the vhost of their host, queues may override it
+		hostVhost := utils.FindHostByName(model.Hosts.PipeHosts, source.Host).AdditionalProps["vhost"]
+		exchangeVhost := hostVhost
+		if exchangeVhost == "" {
+			exchangeVhost = "/"
+		}
+
+		if binding.Destination == binding.Source {
+			return fmt.Errorf("binding %s binds topic %s to itself", binding.Key(), binding.Source)
+		}
+		if queue := utils.FindQueueByName(model.Pipes.Queues, binding.Destination); queue != nil {
+			if queue.Host != source.Host || queue.Configs.VhostName(hostVhost) != exchangeVhost {
+				return fmt.Errorf("binding %s connects pipes of different hosts or vhosts", binding.Key())
+			}
+			//exclusive queues are declared by the filter at runtime, so there is nothing to bind in advance
+			if queue.Configs != nil && queue.Configs.Exclusive {
+				return fmt.Errorf("destination %s of binding %s is exclusive and declared by its filter", binding.Destination, binding.Key())
+			}
+		} else if topic := utils.FindTopicByName(model.Pipes.Topics, binding.Destination); topic != nil {
+			if !isExchange(topic) {
+				return fmt.Errorf("destination %s of binding %s is not an amqp topic of a RabbitMQ host", binding.Destination, binding.Key())
+			}
+			if topic.Host != source.Host {
+				return fmt.Errorf("binding %s connects pipes of different hosts or vhosts", binding.Key())
+			}
+		} else {
+			return fmt.Errorf("destination %s of binding %s is not defined as a queue or topic", binding.Destination, binding.Key())
+		}
+
+		if err := binding.Validate(source.Exchange()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//checks the protocols declared by the type of a pipe host, host types without a declaration accept every protocol
+func (parser *ModelParser) supportsProtocol(model *models.Model, hostName string, protocol string) (*models.Host, bool) {
+	host := utils.FindHostByName(model.Hosts.PipeHosts, hostName)
+	if host == nil {
+		return nil, true
+	}
+	for _, hostType := range parser.hostTypes.PipeHosts {
+		if hostType.Name == host.Type && len(hostType.Protocols) > 0 {
+			return host, contains(hostType.Protocols, protocol)
+		}
+	}
+	return host, true
+}
+
 //checks if the host field of each filter refers to a defined name of a filterHost
 func (parser *ModelParser) checkFilterHosts(model *models.Model) error {
 	filterHosts := make(map[string]bool)
@@ -456,14 +788,17 @@ func (parser *ModelParser) checkFilterMappings(model *models.Model, combinedType
 			protocolMap[p] = true
 		}
 
+		mappingCounts := make(map[string]int)
 		for _, mapping := range filter.Mappings {
-			parts := strings.Split(mapping, ":")
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid mapping format: %s", mapping)
+			if mapping.Port == "" || mapping.Pipe == "" {
+				return fmt.Errorf("invalid mapping %s in filter %s: port and pipe are required", mapping, filter.Name)
+			}
+			if mapping.Prefetch < 0 {
+				return fmt.Errorf("invalid mapping %s in filter %s: prefetch must not be negative", mapping, filter.Name)
 			}
+			mappingCounts[mapping.Port]++
 
-			externalParts := strings.Split(parts[1], "->")
-			pipeName := externalParts[0]
+			pipeName := mapping.Pipe
 			if !definedPipes[pipeName] {
 				return fmt.Errorf("mapping target pipe %s not defined in queues or topics", pipeName)
 			}
@@ -472,38 +807,112 @@ func (parser *ModelParser) checkFilterMappings(model *models.Model, combinedType
 			queue := utils.FindQueueByName(model.Pipes.Queues, pipeName)
 			topic := utils.FindTopicByName(model.Pipes.Topics, pipeName)
 
-			if queue != nil && len(externalParts) > 1 {
+			if queue != nil && mapping.RoutingKey != "" {
 				return fmt.Errorf("routingKey not allowed on queue %s", pipeName)
 			}
 
-			if topic != nil && len(externalParts) > 1 {
+			if topic != nil && mapping.RoutingKey != "" {
 				continue
 			}
 
 			internalPipeFound := false
 			for _, internalPipe := range artifact.InternalPipes {
-				if parts[0] == internalPipe {
+				if mapping.Port == internalPipe.Name {
 					internalPipeFound = true
 					break
 				}
 			}
 			if !internalPipeFound {
-				return fmt.Errorf("mapping internal pipe %s not defined in deployment artifact %s", parts[0], artifact.Name)
+				return fmt.Errorf("mapping internal pipe %s not defined in deployment artifact %s", mapping.Port, artifact.Name)
 			}
 		}
 
-		internalPipesSet := make(map[string]bool)
 		for _, internalPipe := range artifact.InternalPipes {
-			internalPipesSet[internalPipe] = true
+			err := internalPipe.Validate()
+			if err != nil {
+				return fmt.Errorf("deployment artifact %s: %w", artifact.Name, err)
+			}
+
+			min, max, _ := internalPipe.Bounds()
+			count := mappingCounts[internalPipe.Name]
+			if count < min {
+				return fmt.Errorf("internal pipe %s is missing in filter mappings for deployment artifact %s", internalPipe.Name, artifact.Name)
+			}
+			if max >= 0 && count > max {
+				return fmt.Errorf("internal pipe %s of deployment artifact %s is mapped %d times in filter %s but allows at most %d", internalPipe.Name, artifact.Name, count, filter.Name, max)
+			}
 		}
-		mappedPipes := make(map[string]bool)
-		for _, mapping := range filter.Mappings {
-			parts := strings.Split(mapping, ":")
-			mappedPipes[parts[0]] = true
+	}
+
+	return nil
+}
+
+//checks that the secret references of hosts are valid, referenced files have to exist if the model is read from a file.
+//environment variables are not checked, they only have to be set when the model is deployed
+func (parser *ModelParser) checkSecretReferences(model *models.Model, baseDir string) error {
+	hosts := append(append([]models.Host{}, model.Hosts.PipeHosts...), model.Hosts.FilterHosts...)
+	for _, host := range hosts {
+		for prop, value := range host.AdditionalProps {
+			ref, err := models.ParseSecretRef(value)
+			if err != nil {
+				return fmt.Errorf("host %s has an invalid value for property %s: %w", host.Name, prop, err)
+			}
+			//the passwords of the filters of a Mosquitto host are derived from its password, so EICODA has to read it
+			if ref != nil && ref.Source == models.SecretSourceExternal && host.Type == "Mosquitto" && prop == "password" {
+				return fmt.Errorf("the password of Mosquitto host %s cannot be an external secret, the passwords of its filters are derived from it", host.Name)
+			}
+			if ref == nil || ref.Source != models.SecretSourceFile || baseDir == "" {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(baseDir, ref.Name)); err != nil {
+				return fmt.Errorf("secret file %s of host %s cannot be read: %w", ref.Name, host.Name, err)
+			}
 		}
-		for internalPipe := range internalPipesSet {
-			if !mappedPipes[internalPipe] {
-				return fmt.Errorf("internal pipe %s is missing in filter mappings for deployment artifact %s", internalPipe, artifact.Name)
+	}
+	return nil
+}
+
+//checks if the files of file backed configs exist relative to the model and match the schema of their filter type
+func (parser *ModelParser) checkFileConfigs(model *models.Model, baseDir string) error {
+	topology := BuildTopology(model)
+
+	for _, filter := range model.Filters {
+		filterType := utils.FindFilterTypeByName(model.FilterTypes, filter.Type)
+		if filterType == nil {
+			continue
+		}
+
+		for _, config := range filterType.Configs {
+			value, exists := filter.AdditionalProps[config.Name]
+			if !config.IsFile() || !exists {
+				continue
+			}
+
+			filePath := filepath.Join(baseDir, value)
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return fmt.Errorf("file %s of config %s of filter %s cannot be read: %w", filePath, config.Name, filter.Name, err)
+			}
+			if config.Schema == nil {
+				continue
+			}
+
+			var decoded interface{}
+			err = json.Unmarshal(content, &decoded)
+			if err != nil {
+				return fmt.Errorf("file %s of config %s of filter %s is not valid JSON: %w", filePath, config.Name, filter.Name, err)
+			}
+
+			outputPipes := topology.outputPipes(filter.Name)
+			checkFormat := func(format string, value string) error {
+				if format == models.FormatOutputPipe && !outputPipes[value] {
+					return fmt.Errorf("destination %s is not mapped to an output of the filter", value)
+				}
+				return nil
+			}
+			err = config.Schema.Validate(decoded, config.Name, checkFormat)
+			if err != nil {
+				return fmt.Errorf("file %s of filter %s is invalid: %w", filePath, filter.Name, err)
 			}
 		}
 	}
@@ -511,7 +920,19 @@ func (parser *ModelParser) checkFilterMappings(model *models.Model, combinedType
 	return nil
 }
 
-//checks if pipeHosts have type RabbitMQ and filterHosts have type Kubernetes or DockerEngine
+//analyzes the pipes-and-filters graph, prints warnings and fails on errors
+func (parser *ModelParser) checkTopology(model *models.Model) error {
+	report := AnalyzeTopology(model)
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("invalid topology: %s", strings.Join(report.Errors, "; "))
+	}
+	return nil
+}
+
+//checks if pipeHosts have type RabbitMQ or Mosquitto and filterHosts have type Kubernetes or DockerEngine
 func (parser *ModelParser) checkHostTypes(model *models.Model) error {
 	for _, host := range model.Hosts.PipeHosts {
 		valid := false
@@ -520,7 +941,7 @@ func (parser *ModelParser) checkHostTypes(model *models.Model) error {
 				valid = true
 
 				for prop := range host.AdditionalProps {
-					if !contains(ht.Configs, prop) {
+					if !contains(ht.Configs, prop) && !contains(ht.OptionalConfigs, prop) {
 						return fmt.Errorf("pipeHost %s of type %s has invalid property: %s", host.Name, host.Type, prop)
 					}
 				}
@@ -545,7 +966,7 @@ func (parser *ModelParser) checkHostTypes(model *models.Model) error {
 				valid = true
 
 				for prop := range host.AdditionalProps {
-					if !contains(ht.Configs, prop) {
+					if !contains(ht.Configs, prop) && !contains(ht.OptionalConfigs, prop) {
 						return fmt.Errorf("filterHost %s of type %s has invalid property: %s", host.Name, host.Type, prop)
 					}
 				}
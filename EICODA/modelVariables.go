@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//matches ${name} references and the escape sequence $$
+var variablePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+//loop variable of a generator if it does not name one
+const defaultGeneratorVariable = "item"
+
+//resolves the value of a referenced variable
+type variableScope struct {
+	locals    map[string]interface{}
+	overrides map[string]interface{}
+	defaults  map[string]interface{}
+}
+
+//looks up a variable: generator loop variables first, then values given on the command line or in a vars file,
+//then environment variables and finally the defaults declared in the model
+func (scope *variableScope) lookup(name string) (interface{}, bool) {
+	if value, exists := scope.locals[name]; exists {
+		return value, true
+	}
+	if value, exists := scope.overrides[name]; exists {
+		return value, true
+	}
+	if value, exists := os.LookupEnv(name); exists {
+		return parseVariableValue(value), true
+	}
+	value, exists := scope.defaults[name]
+	return value, exists
+}
+
+//returns a scope that additionally knows the given loop variable
+func (scope *variableScope) with(name string, value interface{}) *variableScope {
+	locals := make(map[string]interface{}, len(scope.locals)+1)
+	for key, local := range scope.locals {
+		locals[key] = local
+	}
+	locals[name] = value
+	return &variableScope{locals: locals, overrides: scope.overrides, defaults: scope.defaults}
+}
+
+//parses a value given as text, e.g. with --set, so that numbers, booleans and lists keep their type
+func parseVariableValue(text string) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil || value == nil {
+		return text
+	}
+	return normalizeYAMLValue(value)
+}
+
+//reads the variable values of a vars file, a YAML map of variable names to values
+func LoadVariablesFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vars file: %w", err)
+	}
+	var raw map[string]interface{}
+	err = yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling vars file %s: %w", path, err)
+	}
+	values := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		values[name] = normalizeYAMLValue(value)
+	}
+	return values, nil
+}
+
+//parses key=value assignments given with --set
+func ParseVariableAssignments(assignments []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(assignments))
+	for _, assignment := range assignments {
+		name, value, found := strings.Cut(assignment, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid variable assignment %s, expected key=value", assignment)
+		}
+		values[name] = parseVariableValue(value)
+	}
+	return values, nil
+}
+
+//substitutes the variables of a model and expands its generators, the variables and generators sections are
+//removed from the result. Data without either section and without references is returned unchanged
+func expandModelData(data []byte, overrides map[string]interface{}) ([]byte, error) {
+	if !variablePattern.Match(data) && !strings.Contains(string(data), "generators:") {
+		return data, nil
+	}
+
+	var document yaml.MapSlice
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
+	}
+
+	scope := &variableScope{overrides: overrides, defaults: make(map[string]interface{})}
+	if variables, exists := mapSliceValue(document, "variables"); exists {
+		declared, ok := variables.(yaml.MapSlice)
+		if !ok && variables != nil {
+			return nil, fmt.Errorf("variables must be a map of names to default values")
+		}
+		for _, item := range declared {
+			scope.defaults[fmt.Sprintf("%v", item.Key)] = item.Value
+		}
+	}
+
+	var generators []interface{}
+	if value, exists := mapSliceValue(document, "generators"); exists {
+		list, ok := value.([]interface{})
+		if !ok && value != nil {
+			return nil, fmt.Errorf("generators must be a list")
+		}
+		generators = list
+	}
+
+	var expanded yaml.MapSlice
+	for _, item := range document {
+		if item.Key == "variables" || item.Key == "generators" {
+			continue
+		}
+		value, err := substituteVariables(item.Value, scope)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, yaml.MapItem{Key: item.Key, Value: value})
+	}
+
+	for i, generator := range generators {
+		expanded, err = expandGenerator(expanded, generator, scope)
+		if err != nil {
+			return nil, fmt.Errorf("generator %d: %w", i+1, err)
+		}
+	}
+
+	return yaml.Marshal(expanded)
+}
+
+//stamps out the filters, queues, topics and bindings of a generator once per item and appends them to the document
+func expandGenerator(document yaml.MapSlice, raw interface{}, scope *variableScope) (yaml.MapSlice, error) {
+	generator, ok := raw.(yaml.MapSlice)
+	if !ok {
+		return nil, fmt.Errorf("a generator must be a map")
+	}
+
+	variable := defaultGeneratorVariable
+	if value, exists := mapSliceValue(generator, "as"); exists {
+		variable = fmt.Sprintf("%v", value)
+	}
+
+	items, err := generatorItems(generator, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string][]interface{}{}
+	for _, section := range []string{"filters", "queues", "topics", "bindings"} {
+		value, exists := mapSliceValue(generator, section)
+		if !exists {
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s of a generator must be a list", section)
+		}
+		templates[section] = list
+	}
+
+	for _, item := range items {
+		itemScope := scope.with(variable, item)
+		for _, section := range []string{"filters", "queues", "topics", "bindings"} {
+			for _, template := range templates[section] {
+				value, err := substituteVariables(template, itemScope)
+				if err != nil {
+					return nil, err
+				}
+				switch section {
+				case "filters":
+					document = appendToList(document, []string{"filters"}, value)
+				default:
+					document = appendToList(document, []string{"pipes", section}, value)
+				}
+			}
+		}
+	}
+
+	return document, nil
+}
+
+//returns the items a generator iterates over, either the list of forEach or the numbers of range (both bounds included)
+func generatorItems(generator yaml.MapSlice, scope *variableScope) ([]interface{}, error) {
+	if value, exists := mapSliceValue(generator, "forEach"); exists {
+		value, err := substituteVariables(value, scope)
+		if err != nil {
+			return nil, err
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("forEach must be a list")
+		}
+		return items, nil
+	}
+
+	if value, exists := mapSliceValue(generator, "range"); exists {
+		value, err := substituteVariables(value, scope)
+		if err != nil {
+			return nil, err
+		}
+		bounds, ok := value.(yaml.MapSlice)
+		if !ok {
+			return nil, fmt.Errorf("range must be a map with from and to")
+		}
+		fromValue, _ := mapSliceValue(bounds, "from")
+		toValue, _ := mapSliceValue(bounds, "to")
+		from, err := integerValue(fromValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range start: %w", err)
+		}
+		to, err := integerValue(toValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range end: %w", err)
+		}
+
+		var items []interface{}
+		for i := from; i <= to; i++ {
+			items = append(items, i)
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("a generator needs forEach or range")
+}
+
+//replaces the variable references in all strings of a value. A string that consists of a single reference
+//takes the value of the variable with its type, e.g. a number or a list
+func substituteVariables(value interface{}, scope *variableScope) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		match := variablePattern.FindStringSubmatchIndex(typed)
+		if match != nil && match[0] == 0 && match[1] == len(typed) && match[2] >= 0 {
+			name := typed[match[2]:match[3]]
+			resolved, exists := scope.lookup(name)
+			if !exists {
+				return nil, fmt.Errorf("undefined variable %s", name)
+			}
+			return resolved, nil
+		}
+
+		var undefined string
+		result := variablePattern.ReplaceAllStringFunc(typed, func(reference string) string {
+			if reference == "$$" {
+				return "$"
+			}
+			name := reference[2 : len(reference)-1]
+			resolved, exists := scope.lookup(name)
+			if !exists {
+				undefined = name
+				return reference
+			}
+			return fmt.Sprintf("%v", resolved)
+		})
+		if undefined != "" {
+			return nil, fmt.Errorf("undefined variable %s", undefined)
+		}
+		return result, nil
+	case yaml.MapSlice:
+		result := make(yaml.MapSlice, 0, len(typed))
+		for _, item := range typed {
+			substituted, err := substituteVariables(item.Value, scope)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, yaml.MapItem{Key: item.Key, Value: substituted})
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			substituted, err := substituteVariables(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, substituted)
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+//returns the value of a key in a YAML map
+func mapSliceValue(slice yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range slice {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+//appends a value to the list at the given path of nested maps, missing maps and lists are created
+func appendToList(slice yaml.MapSlice, path []string, value interface{}) yaml.MapSlice {
+	for i, item := range slice {
+		if item.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			list, _ := item.Value.([]interface{})
+			slice[i].Value = append(list, value)
+		} else {
+			nested, _ := item.Value.(yaml.MapSlice)
+			slice[i].Value = appendToList(nested, path[1:], value)
+		}
+		return slice
+	}
+
+	if len(path) == 1 {
+		return append(slice, yaml.MapItem{Key: path[0], Value: []interface{}{value}})
+	}
+	return append(slice, yaml.MapItem{Key: path[0], Value: appendToList(nil, path[1:], value)})
+}
+
+//converts a number given as YAML value or text to an int
+func integerValue(value interface{}) (int, error) {
+	switch typed := value.(type) {
+	case int:
+		return typed, nil
+	case string:
+		number, err := strconv.Atoi(typed)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an int", typed)
+		}
+		return number, nil
+	default:
+		return 0, fmt.Errorf("%v is not an int", value)
+	}
+}
+
+//converts the nested maps yaml.v2 decodes into interface{} values to MapSlices, so they can be substituted like the model itself
+func normalizeYAMLValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		var slice yaml.MapSlice
+		for key, item := range typed {
+			slice = append(slice, yaml.MapItem{Key: key, Value: normalizeYAMLValue(item)})
+		}
+		return slice
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = normalizeYAMLValue(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//types of the exchanges topics of RabbitMQ hosts become
+const (
+	ExchangeTypeTopic   = "topic"
+	ExchangeTypeFanout  = "fanout"
+	ExchangeTypeDirect  = "direct"
+	ExchangeTypeHeaders = "headers"
+)
+
+var ExchangeTypes = []string{ExchangeTypeTopic, ExchangeTypeFanout, ExchangeTypeDirect, ExchangeTypeHeaders}
+
+//values of the x-match argument of a binding to a headers exchange
+var HeaderMatchModes = []string{"all", "any", "all-with-x", "any-with-x"}
+
+//routes the messages of a topic to a queue or another topic. Topic and direct exchanges route by the routing key,
+//headers exchanges by the header values in arguments, where x-match selects whether all or any have to match
+type Binding struct {
+	Source      string            `yaml:"source"`
+	Destination string            `yaml:"destination"`
+	RoutingKey  string            `yaml:"routingKey,omitempty"`
+	Arguments   map[string]string `yaml:"arguments,omitempty"`
+}
+
+//returns the type of the exchange of a topic, topic if none is set
+func (t Topic) Exchange() string {
+	if t.ExchangeType == "" {
+		return ExchangeTypeTopic
+	}
+	return t.ExchangeType
+}
+
+//identifies a binding, e.g. Orders -> Invoices (order.*) or Orders -> Audit [type=order]
+func (b Binding) Key() string {
+	key := b.Source + " -> " + b.Destination
+	if b.RoutingKey != "" {
+		key += " (" + b.RoutingKey + ")"
+	}
+	if len(b.Arguments) > 0 {
+		var arguments []string
+		for name, value := range b.Arguments {
+			arguments = append(arguments, name+"="+value)
+		}
+		sort.Strings(arguments)
+		key += " [" + strings.Join(arguments, ", ") + "]"
+	}
+	return key
+}
+
+//checks that the routing key and arguments fit the type of the source exchange
+func (b Binding) Validate(exchangeType string) error {
+	switch exchangeType {
+	case ExchangeTypeFanout:
+		if b.RoutingKey != "" || len(b.Arguments) > 0 {
+			return fmt.Errorf("fanout exchanges route every message, binding %s cannot have a routingKey or arguments", b.Key())
+		}
+	case ExchangeTypeHeaders:
+		if b.RoutingKey != "" {
+			return fmt.Errorf("headers exchanges route by arguments, binding %s cannot have a routingKey", b.Key())
+		}
+		if len(b.Arguments) == 0 {
+			return fmt.Errorf("binding %s of a headers exchange needs arguments with the header values to match", b.Key())
+		}
+		if match, exists := b.Arguments["x-match"]; exists && !containsString(HeaderMatchModes, match) {
+			return fmt.Errorf("invalid x-match %s of binding %s, expected one of %s", match, b.Key(), strings.Join(HeaderMatchModes, ", "))
+		}
+	default:
+		if len(b.Arguments) > 0 {
+			return fmt.Errorf("only headers exchanges use arguments, binding %s routes by its routingKey", b.Key())
+		}
+	}
+	return nil
+}
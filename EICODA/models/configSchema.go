@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+//format of strings in a config file that name a pipe the filter publishes to
+const FormatOutputPipe = "outputPipe"
+
+//describes the expected content of a file backed config as a subset of JSON Schema
+type ConfigSchema struct {
+	Type                 string                   `yaml:"type,omitempty"`
+	Properties           map[string]*ConfigSchema `yaml:"properties,omitempty"`
+	Required             []string                 `yaml:"required,omitempty"`
+	AdditionalProperties *ConfigSchema            `yaml:"additionalProperties,omitempty"`
+	Items                *ConfigSchema            `yaml:"items,omitempty"`
+	MinItems             int                      `yaml:"minItems,omitempty"`
+	Format               string                   `yaml:"format,omitempty"`
+}
+
+//checks a string value with a format, e.g. whether it names an output pipe of the filter
+type FormatChecker func(format string, value string) error
+
+//validates a decoded JSON value against the schema, path names the position of the value in error messages
+func (s *ConfigSchema) Validate(value interface{}, path string, checkFormat FormatChecker) error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, exists := object[name]; !exists {
+				return fmt.Errorf("%s is missing required property %s", path, name)
+			}
+		}
+
+		var names []string
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propertySchema, exists := s.Properties[name]
+			if !exists {
+				propertySchema = s.AdditionalProperties
+			}
+			if err := propertySchema.Validate(object[name], path+"."+name, checkFormat); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if len(array) < s.MinItems {
+			return fmt.Errorf("%s must not have fewer than %d items", path, s.MinItems)
+		}
+		for i, item := range array {
+			if err := s.Items.Validate(item, fmt.Sprintf("%s[%d]", path, i), checkFormat); err != nil {
+				return err
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if s.Format != "" && checkFormat != nil {
+			if err := checkFormat(s.Format, text); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	case "":
+	default:
+		return fmt.Errorf("unknown schema type %s at %s", s.Type, path)
+	}
+
+	return nil
+}
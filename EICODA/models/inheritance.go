@@ -0,0 +1,117 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+//resolves the inheritance hierarchy of filter types. Every type gets the configs of its parents, a config with
+//the name of an inherited one overrides the fields it sets and removes it if it is marked as removed.
+//fails on missing parents and cycles, the resolved types keep the order of the given ones
+func ResolveFilterTypes(filterTypes []FilterType) ([]FilterType, error) {
+	typeMap := make(map[string]FilterType)
+	for _, filterType := range filterTypes {
+		typeMap[filterType.Name] = filterType
+	}
+
+	resolved := make(map[string]FilterType)
+	visiting := make(map[string]bool)
+
+	var resolve func(name string, chain []string) (FilterType, error)
+	resolve = func(name string, chain []string) (FilterType, error) {
+		if filterType, done := resolved[name]; done {
+			return filterType, nil
+		}
+		chain = append(chain, name)
+		if visiting[name] {
+			return FilterType{}, fmt.Errorf("inheritance cycle between filter types: %s", strings.Join(chain, " -> "))
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		filterType := typeMap[name]
+		result := filterType
+		result.Configs = nil
+
+		if filterType.DerivedFrom != "" {
+			if _, exists := typeMap[filterType.DerivedFrom]; !exists {
+				return FilterType{}, fmt.Errorf("parent filter type %s not found for filter type %s", filterType.DerivedFrom, name)
+			}
+			parent, err := resolve(filterType.DerivedFrom, chain)
+			if err != nil {
+				return FilterType{}, err
+			}
+			result.Configs = append(result.Configs, parent.Configs...)
+			if result.Artifact == "" {
+				result.Artifact = parent.Artifact
+			}
+			//a subtype of a stateful type keeps its state, so it cannot be declared scalable
+			result.Stateful = result.Stateful || parent.Stateful
+		}
+
+		for _, config := range filterType.Configs {
+			index := -1
+			for i, inherited := range result.Configs {
+				if inherited.Name == config.Name {
+					index = i
+					break
+				}
+			}
+
+			switch {
+			case index >= 0 && config.Removed:
+				result.Configs = append(result.Configs[:index:index], result.Configs[index+1:]...)
+			case index >= 0:
+				result.Configs[index] = overrideConfig(result.Configs[index], config, name)
+			case config.Removed:
+				return FilterType{}, fmt.Errorf("filter type %s removes config %s that it does not inherit", name, config.Name)
+			default:
+				config.Source = name
+				result.Configs = append(result.Configs, config)
+			}
+		}
+
+		resolved[name] = result
+		return result, nil
+	}
+
+	var result []FilterType
+	for _, filterType := range filterTypes {
+		resolvedType, err := resolve(filterType.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resolvedType)
+	}
+	return result, nil
+}
+
+//applies the fields a subtype sets on an inherited config
+func overrideConfig(inherited FilterConfig, override FilterConfig, typeName string) FilterConfig {
+	if override.Default != nil {
+		inherited.Default = override.Default
+	}
+	if override.File {
+		inherited.File = true
+	}
+	if override.Type != "" {
+		inherited.Type = override.Type
+	}
+	if override.Values != nil {
+		inherited.Values = override.Values
+	}
+	if override.Min != nil {
+		inherited.Min = override.Min
+	}
+	if override.Max != nil {
+		inherited.Max = override.Max
+	}
+	if override.Description != "" {
+		inherited.Description = override.Description
+	}
+	if override.Schema != nil {
+		inherited.Schema = override.Schema
+	}
+	inherited.OverriddenIn = typeName
+	return inherited
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//settings of the kafka topic a queue or topic of a Kafka host becomes, unset values are left to the broker
+type KafkaSettings struct {
+	Partitions        int `yaml:"partitions,omitempty"`
+	ReplicationFactor int `yaml:"replicationFactor,omitempty"`
+	//how long messages are kept, e.g. 7d, 12h, milliseconds or -1 for unlimited
+	Retention string `yaml:"retention,omitempty"`
+}
+
+//returns true if any setting is given
+func (s KafkaSettings) IsSet() bool {
+	return s.Partitions != 0 || s.ReplicationFactor != 0 || s.Retention != ""
+}
+
+//checks that the numbers are positive and the retention can be parsed
+func (s KafkaSettings) Validate() error {
+	if s.Partitions < 0 {
+		return fmt.Errorf("invalid number of partitions: %d", s.Partitions)
+	}
+	if s.ReplicationFactor < 0 {
+		return fmt.Errorf("invalid replication factor: %d", s.ReplicationFactor)
+	}
+	if s.Retention != "" {
+		if _, err := s.RetentionMilliseconds(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//returns the retention in milliseconds as kafka expects it in retention.ms. Plain numbers are taken as
+//milliseconds, durations may use the units of Go and d for days
+func (s KafkaSettings) RetentionMilliseconds() (int64, error) {
+	value := strings.TrimSpace(s.Retention)
+	if milliseconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if milliseconds < -1 {
+			return 0, fmt.Errorf("invalid retention %q, use -1 for unlimited", s.Retention)
+		}
+		return milliseconds, nil
+	}
+
+	if days, found := strings.CutSuffix(value, "d"); found {
+		number, err := strconv.ParseInt(days, 10, 64)
+		if err == nil && number >= 0 {
+			return (time.Duration(number) * 24 * time.Hour).Milliseconds(), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%q is not a retention (e.g. 7d, 12h, milliseconds or -1)", s.Retention)
+	}
+	return duration.Milliseconds(), nil
+}
@@ -1,14 +1,28 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Model struct {
-	Pipes struct {
-		Queues []Queue `yaml:"queues"`
-		Topics []Topic `yaml:"topics"`
+	Imports []string `yaml:"imports,omitempty"`
+	Pipes   struct {
+		Queues   []Queue   `yaml:"queues"`
+		Topics   []Topic   `yaml:"topics"`
+		Bindings []Binding `yaml:"bindings,omitempty"`
 	} `yaml:"pipes"`
 	Filters             []Filter             `yaml:"filters"`
 	Hosts               Hosts                `yaml:"hosts"`
 	FilterTypes         []FilterType         `yaml:"filterTypes"`
 	DeploymentArtifacts []DeploymentArtifact `yaml:"deploymentArtifacts"`
+	Profiles            map[string]Profile   `yaml:"profiles,omitempty"`
 }
 
 type Queue struct {
@@ -16,7 +30,10 @@ type Queue struct {
 	Name     string `yaml:"name"`
 	Host     string `yaml:"host"`
 	Protocol string `yaml:"protocol"`
-	Configs  string `yaml:"configs"`
+	//settings of the queue on RabbitMQ hosts
+	Configs *QueueConfigs `yaml:"configs,omitempty"`
+	//settings of the kafka topic a queue of a Kafka host becomes
+	KafkaSettings `yaml:",inline"`
 }
 
 type Topic struct {
@@ -24,6 +41,10 @@ type Topic struct {
 	Name     string `yaml:"name"`
 	Host     string `yaml:"host"`
 	Protocol string `yaml:"protocol"`
+	//type of the exchange of a topic of a RabbitMQ host, topic if none is set
+	ExchangeType string `yaml:"exchangeType,omitempty"`
+	//settings of the kafka topic a topic of a Kafka host becomes
+	KafkaSettings `yaml:",inline"`
 }
 
 type Filter struct {
@@ -31,11 +52,96 @@ type Filter struct {
 	Name             string            `yaml:"name"`
 	Host             string            `yaml:"host"`
 	Type             string            `yaml:"type"`
-	Mappings         []string          `yaml:"mappings"`
+	Mappings         []Mapping         `yaml:"mappings"`
 	Artifact         string            `yaml:"artifact"`
+	Replicas         *int              `yaml:"replicas,omitempty"`
+	Enabled          *bool             `yaml:"enabled,omitempty"`
 	AdditionalProps  map[string]string `yaml:",inline"`
 }
 
+//returns false if the filter is disabled in the model or by a profile
+func (f Filter) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+//returns the number of instances of the filter, one if no replicas are set
+func (f Filter) ReplicaCount() int {
+	if f.Replicas == nil {
+		return 1
+	}
+	return *f.Replicas
+}
+
+//represents the mapping of an internal pipe (port) of a filter to a queue or topic of the model
+type Mapping struct {
+	Port       string            `yaml:"port"`
+	Pipe       string            `yaml:"pipe"`
+	RoutingKey string            `yaml:"routingKey,omitempty"`
+	Prefetch   int               `yaml:"prefetch,omitempty"`
+	Arguments  map[string]string `yaml:"arguments,omitempty"`
+}
+
+//accepts the legacy form internal:pipe->routingKey as well as the structured form
+func (m *Mapping) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		mapping, err := ParseMapping(value)
+		if err != nil {
+			return err
+		}
+		*m = mapping
+		return nil
+	}
+
+	type rawMapping Mapping
+	var raw rawMapping
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*m = Mapping(raw)
+	return nil
+}
+
+//parses the legacy form internal:pipe->routingKey, the port ends at the first colon and the routing key starts at the first arrow
+func ParseMapping(value string) (Mapping, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return Mapping{}, fmt.Errorf("invalid mapping format: %s", value)
+	}
+
+	mapping := Mapping{Port: parts[0], Pipe: parts[1]}
+	if pipeParts := strings.SplitN(parts[1], "->", 2); len(pipeParts) == 2 {
+		mapping.Pipe = pipeParts[0]
+		mapping.RoutingKey = pipeParts[1]
+	}
+	return mapping, nil
+}
+
+//returns the mapping in the legacy notation followed by its additional attributes
+func (m Mapping) String() string {
+	value := m.Port + ":" + m.Pipe
+	if m.RoutingKey != "" {
+		value += "->" + m.RoutingKey
+	}
+
+	var attributes []string
+	if m.Prefetch != 0 {
+		attributes = append(attributes, fmt.Sprintf("prefetch=%d", m.Prefetch))
+	}
+	var keys []string
+	for key := range m.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attributes = append(attributes, fmt.Sprintf("%s=%s", key, m.Arguments[key]))
+	}
+	if len(attributes) > 0 {
+		value += " (" + strings.Join(attributes, ", ") + ")"
+	}
+	return value
+}
+
 type Hosts struct {
 	PipeHosts   []Host `yaml:"pipeHosts"`
 	FilterHosts []Host `yaml:"filterHosts"`
@@ -53,20 +159,248 @@ type FilterType struct {
 	Artifact    string        `yaml:"artifact,omitempty"`
 	Configs     []FilterConfig `yaml:"configs,omitempty"`
 	DerivedFrom string        `yaml:"derivedFrom,omitempty"`
+	Abstract    bool          `yaml:"abstract,omitempty"`
+	//stateful filters keep messages in memory (e.g. to aggregate or resequence them) and must not be scaled
+	Stateful    bool          `yaml:"stateful,omitempty"`
 }
 
 type FilterConfig struct {
-	Name    string      `yaml:"name"`
-	Default interface{} `yaml:"default,omitempty"`
-	File    bool        `yaml:"file,omitempty"`
+	Name        string        `yaml:"name"`
+	Default     interface{}   `yaml:"default,omitempty"`
+	File        bool          `yaml:"file,omitempty"`
+	Type        string        `yaml:"type,omitempty"`
+	Values      []string      `yaml:"values,omitempty"`
+	Min         *float64      `yaml:"min,omitempty"`
+	Max         *float64      `yaml:"max,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Schema      *ConfigSchema `yaml:"schema,omitempty"`
+	Removed     bool          `yaml:"removed,omitempty"`
+
+	//set when inheritance is resolved: the type that defines the config and the last subtype that overrides it
+	Source       string `yaml:"-" json:"-"`
+	OverriddenIn string `yaml:"-" json:"-"`
+}
+
+//types of filter config values, configs without a type accept any value
+const (
+	ConfigTypeString   = "string"
+	ConfigTypeInt      = "int"
+	ConfigTypeFloat    = "float"
+	ConfigTypeBool     = "bool"
+	ConfigTypeJSON     = "json"
+	ConfigTypeEnum     = "enum"
+	ConfigTypeDuration = "duration"
+	ConfigTypeRegex    = "regex"
+	ConfigTypeFile     = "file"
+)
+
+//returns true if the value of the config is the path of a file whose content is passed to the filter
+func (c FilterConfig) IsFile() bool {
+	return c.File || c.Type == ConfigTypeFile
+}
+
+//checks if the definition of the config is valid, including its default value
+func (c FilterConfig) Check() error {
+	if c.Name == "" {
+		return fmt.Errorf("config name cannot be empty")
+	}
+
+	switch c.Type {
+	case "", ConfigTypeString, ConfigTypeInt, ConfigTypeFloat, ConfigTypeBool, ConfigTypeJSON, ConfigTypeDuration, ConfigTypeRegex, ConfigTypeFile:
+	case ConfigTypeEnum:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("config %s of type enum needs allowed values", c.Name)
+		}
+	default:
+		return fmt.Errorf("config %s has unknown type %s", c.Name, c.Type)
+	}
+
+	if (c.Min != nil || c.Max != nil) && c.Type != ConfigTypeInt && c.Type != ConfigTypeFloat && c.Type != ConfigTypeDuration {
+		return fmt.Errorf("config %s of type %s cannot have min or max", c.Name, c.Type)
+	}
+	if c.Schema != nil && !c.IsFile() {
+		return fmt.Errorf("config %s has a schema but is not file backed", c.Name)
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return fmt.Errorf("config %s has a min greater than its max", c.Name)
+	}
+
+	if c.Default != nil {
+		if _, err := c.NormalizeValue(fmt.Sprintf("%v", c.Default)); err != nil {
+			return fmt.Errorf("invalid default of config %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+//validates a value against the type of the config and returns it in its canonical form.
+//durations are returned in milliseconds, values of untyped configs are returned unchanged
+func (c FilterConfig) NormalizeValue(value string) (string, error) {
+	var number float64
+	normalized := value
+
+	switch c.Type {
+	case ConfigTypeInt:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an int", value)
+		}
+		number = float64(parsed)
+		normalized = strconv.FormatInt(parsed, 10)
+	case ConfigTypeFloat:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a float", value)
+		}
+		number = parsed
+		normalized = strconv.FormatFloat(parsed, 'f', -1, 64)
+	case ConfigTypeBool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", value)
+		}
+		normalized = strconv.FormatBool(parsed)
+	case ConfigTypeJSON:
+		var buffer bytes.Buffer
+		if err := json.Compact(&buffer, []byte(value)); err != nil {
+			return "", fmt.Errorf("%q is not valid JSON: %v", value, err)
+		}
+		normalized = buffer.String()
+	case ConfigTypeEnum:
+		allowed := false
+		for _, allowedValue := range c.Values {
+			if value == allowedValue {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("%q is not one of %s", value, strings.Join(c.Values, ", "))
+		}
+	case ConfigTypeDuration:
+		//plain numbers are taken as milliseconds
+		milliseconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			duration, durationErr := time.ParseDuration(strings.TrimSpace(value))
+			if durationErr != nil {
+				return "", fmt.Errorf("%q is not a duration (e.g. 500ms, 3s, 1m or milliseconds)", value)
+			}
+			milliseconds = duration.Milliseconds()
+		}
+		number = float64(milliseconds)
+		normalized = strconv.FormatInt(milliseconds, 10)
+	case ConfigTypeRegex:
+		if _, err := regexp.Compile(value); err != nil {
+			return "", fmt.Errorf("%q is not a valid regular expression: %v", value, err)
+		}
+	case ConfigTypeString, ConfigTypeFile:
+		if value == "" {
+			return "", fmt.Errorf("value cannot be empty")
+		}
+	}
+
+	if c.Min != nil && number < *c.Min {
+		return "", fmt.Errorf("%s is less than the minimum %v", normalized, *c.Min)
+	}
+	if c.Max != nil && number > *c.Max {
+		return "", fmt.Errorf("%s is greater than the maximum %v", normalized, *c.Max)
+	}
+	return normalized, nil
 }
 
 type DeploymentArtifact struct {
-	Name          string   `yaml:"name"`
-	Type          string   `yaml:"type"`
-	Image         string   `yaml:"image"`
-	Protocol      string   `yaml:"protocol"`
-	InternalPipes []string `yaml:"internalPipes"`
+	Name          string         `yaml:"name"`
+	Type          string         `yaml:"type"`
+	Image         string         `yaml:"image"`
+	Protocol      string         `yaml:"protocol"`
+	InternalPipes []InternalPipe `yaml:"internalPipes"`
+}
+
+//directions in which messages flow through an internal pipe of a deployment artifact
+const (
+	DirectionInput  = "input"
+	DirectionOutput = "output"
+	DirectionBoth   = "both"
+)
+
+//represents a port of a deployment artifact that filters map to a pipe of the model.
+//cardinality limits how often the port can be mapped: 1 (default), 0..1, 1..* or 0..*
+type InternalPipe struct {
+	Name        string `yaml:"name"`
+	Direction   string `yaml:"direction,omitempty"`
+	Cardinality string `yaml:"cardinality,omitempty"`
+}
+
+//accepts the legacy form that only consists of the name as well as the structured form. Legacy internal pipes
+//could always be mapped more than once, so they keep an unbounded cardinality
+func (p *InternalPipe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*p = InternalPipe{Name: name, Cardinality: "1..*"}
+		return nil
+	}
+
+	type rawInternalPipe InternalPipe
+	var raw rawInternalPipe
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*p = InternalPipe(raw)
+	return nil
+}
+
+//returns the minimum and maximum number of mappings allowed for the internal pipe, a maximum of -1 means unbounded
+func (p InternalPipe) Bounds() (int, int, error) {
+	switch p.Cardinality {
+	case "", "1":
+		return 1, 1, nil
+	case "0..1":
+		return 0, 1, nil
+	case "1..*":
+		return 1, -1, nil
+	case "0..*":
+		return 0, -1, nil
+	}
+	return 0, 0, fmt.Errorf("invalid cardinality %s of internal pipe %s", p.Cardinality, p.Name)
+}
+
+//checks if direction and cardinality of the internal pipe have valid values
+func (p InternalPipe) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("internal pipe name cannot be empty")
+	}
+
+	switch p.Direction {
+	case "", DirectionInput, DirectionOutput, DirectionBoth:
+	default:
+		return fmt.Errorf("invalid direction %s of internal pipe %s", p.Direction, p.Name)
+	}
+
+	_, _, err := p.Bounds()
+	return err
+}
+
+var (
+	inputPortPattern  = regexp.MustCompile(`^in([A-Z0-9_].*)?$`)
+	outputPortPattern = regexp.MustCompile(`^out([A-Z0-9_].*)?$`)
+)
+
+//returns the direction the deployment artifact declares for an internal pipe. Without a declaration it is derived
+//from the name (in, inOne, out, outTwo, ...) and unknown names count as both directions
+func (a DeploymentArtifact) PortDirection(port string) (string, bool) {
+	for _, internalPipe := range a.InternalPipes {
+		if internalPipe.Name == port && internalPipe.Direction != "" {
+			return internalPipe.Direction, true
+		}
+	}
+
+	switch {
+	case inputPortPattern.MatchString(port):
+		return DirectionInput, false
+	case outputPortPattern.MatchString(port):
+		return DirectionOutput, false
+	}
+	return DirectionBoth, false
 }
 
 type CombinedTypes struct {
@@ -83,4 +417,8 @@ type HostTypes struct {
 type HostType struct {
 	Name    string   `yaml:"name"`
 	Configs []string `yaml:"configs"`
+	//configs a host of the type may set, e.g. mqtt_port of RabbitMQ
+	OptionalConfigs []string `yaml:"optionalConfigs,omitempty"`
+	//protocols the pipes of a pipe host of the type can use
+	Protocols []string `yaml:"protocols,omitempty"`
 }
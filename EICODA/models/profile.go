@@ -0,0 +1,190 @@
+package models
+
+import "fmt"
+
+//environment specific patches of a model, e.g. for dev and prod
+type Profile struct {
+	Hosts   Hosts         `yaml:"hosts,omitempty"`
+	Filters []FilterPatch `yaml:"filters,omitempty"`
+	Pipes   struct {
+		Queues []PipePatch `yaml:"queues,omitempty"`
+		Topics []PipePatch `yaml:"topics,omitempty"`
+	} `yaml:"pipes,omitempty"`
+}
+
+//changes a filter of the base model that is matched by name, only the set fields are applied
+type FilterPatch struct {
+	Name            string            `yaml:"name"`
+	Host            string            `yaml:"host,omitempty"`
+	Artifact        string            `yaml:"artifact,omitempty"`
+	Replicas        *int              `yaml:"replicas,omitempty"`
+	Mappings        []Mapping         `yaml:"mappings,omitempty"`
+	Enabled         *bool             `yaml:"enabled,omitempty"`
+	AdditionalProps map[string]string `yaml:",inline"`
+}
+
+//changes a queue or topic of the base model that is matched by name, only the set fields are applied
+type PipePatch struct {
+	Name     string `yaml:"name"`
+	Host     string `yaml:"host,omitempty"`
+	Protocol string `yaml:"protocol,omitempty"`
+	//replaces the configs of a queue as a whole
+	Configs *QueueConfigs `yaml:"configs,omitempty"`
+
+	KafkaSettings `yaml:",inline"`
+}
+
+//applies the profiles with the given names in order. Hosts of a profile are added to the model or replace hosts
+//with the same name, patches of filters and pipes must match an element of the model. Afterwards the disabled
+//filters and the profiles are removed from the model
+func (m *Model) ApplyProfiles(names []string) error {
+	for _, name := range names {
+		profile, exists := m.Profiles[name]
+		if !exists {
+			return fmt.Errorf("profile %s is not defined in the model", name)
+		}
+
+		m.Hosts.PipeHosts = overlayHosts(m.Hosts.PipeHosts, profile.Hosts.PipeHosts)
+		m.Hosts.FilterHosts = overlayHosts(m.Hosts.FilterHosts, profile.Hosts.FilterHosts)
+
+		for _, patch := range profile.Filters {
+			if err := m.patchFilter(patch); err != nil {
+				return fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+		for _, patch := range profile.Pipes.Queues {
+			if err := m.patchQueue(patch); err != nil {
+				return fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+		for _, patch := range profile.Pipes.Topics {
+			if err := m.patchTopic(patch); err != nil {
+				return fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+	}
+
+	var enabled []Filter
+	for _, filter := range m.Filters {
+		if filter.IsEnabled() {
+			filter.Enabled = nil
+			enabled = append(enabled, filter)
+		}
+	}
+	m.Filters = enabled
+	m.Profiles = nil
+	return nil
+}
+
+func (m *Model) patchFilter(patch FilterPatch) error {
+	for i := range m.Filters {
+		filter := &m.Filters[i]
+		if filter.Name != patch.Name {
+			continue
+		}
+
+		if patch.Enabled != nil {
+			filter.Enabled = patch.Enabled
+		}
+		if patch.Host != "" {
+			filter.Host = patch.Host
+		}
+		if patch.Artifact != "" {
+			filter.Artifact = patch.Artifact
+		}
+		if patch.Replicas != nil {
+			filter.Replicas = patch.Replicas
+		}
+		if patch.Mappings != nil {
+			filter.Mappings = patch.Mappings
+		}
+		if len(patch.AdditionalProps) > 0 {
+			props := make(map[string]string, len(filter.AdditionalProps)+len(patch.AdditionalProps))
+			for key, value := range filter.AdditionalProps {
+				props[key] = value
+			}
+			for key, value := range patch.AdditionalProps {
+				props[key] = value
+			}
+			filter.AdditionalProps = props
+		}
+		return nil
+	}
+	return fmt.Errorf("filter %s not found in the model", patch.Name)
+}
+
+func (m *Model) patchQueue(patch PipePatch) error {
+	for i := range m.Pipes.Queues {
+		queue := &m.Pipes.Queues[i]
+		if queue.Name != patch.Name {
+			continue
+		}
+
+		if patch.Host != "" {
+			queue.Host = patch.Host
+		}
+		if patch.Protocol != "" {
+			queue.Protocol = patch.Protocol
+		}
+		if patch.Configs != nil {
+			queue.Configs = patch.Configs
+		}
+		queue.KafkaSettings = patchKafkaSettings(queue.KafkaSettings, patch.KafkaSettings)
+		return nil
+	}
+	return fmt.Errorf("queue %s not found in the model", patch.Name)
+}
+
+func (m *Model) patchTopic(patch PipePatch) error {
+	for i := range m.Pipes.Topics {
+		topic := &m.Pipes.Topics[i]
+		if topic.Name != patch.Name {
+			continue
+		}
+
+		if patch.Host != "" {
+			topic.Host = patch.Host
+		}
+		if patch.Protocol != "" {
+			topic.Protocol = patch.Protocol
+		}
+		if patch.Configs != nil {
+			return fmt.Errorf("topic %s cannot have configs", patch.Name)
+		}
+		topic.KafkaSettings = patchKafkaSettings(topic.KafkaSettings, patch.KafkaSettings)
+		return nil
+	}
+	return fmt.Errorf("topic %s not found in the model", patch.Name)
+}
+
+//applies the kafka settings a patch sets
+func patchKafkaSettings(settings KafkaSettings, patch KafkaSettings) KafkaSettings {
+	if patch.Partitions != 0 {
+		settings.Partitions = patch.Partitions
+	}
+	if patch.ReplicationFactor != 0 {
+		settings.ReplicationFactor = patch.ReplicationFactor
+	}
+	if patch.Retention != "" {
+		settings.Retention = patch.Retention
+	}
+	return settings
+}
+
+//adds the hosts of a profile, a host with the name of an existing one replaces it
+func overlayHosts(hosts []Host, overlay []Host) []Host {
+	for _, host := range overlay {
+		replaced := false
+		for i := range hosts {
+			if hosts[i].Name == host.Name {
+				hosts[i] = host
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//types of RabbitMQ queues
+const (
+	QueueTypeClassic = "classic"
+	QueueTypeQuorum  = "quorum"
+	QueueTypeStream  = "stream"
+)
+
+//ways the settings that RabbitMQ allows to change at runtime are applied to a queue
+const (
+	//a policy per queue, changing it does not require to recreate the queue
+	ApplyAsPolicy = "policy"
+	//arguments of the queue declaration
+	ApplyAsArguments = "arguments"
+)
+
+//behaviours of a queue that reached its max length
+var QueueOverflowModes = []string{"drop-head", "reject-publish", "reject-publish-dlx"}
+
+//settings of a queue of a RabbitMQ host, unset values keep the defaults of RabbitMQ
+type QueueConfigs struct {
+	Vhost      string `yaml:"vhost,omitempty"`
+	Type       string `yaml:"type,omitempty"`
+	Durable    *bool  `yaml:"durable,omitempty"`
+	AutoDelete bool   `yaml:"autoDelete,omitempty"`
+	//exclusive queues belong to the connection of the filter that declares them, so they are not created in advance
+	Exclusive   bool `yaml:"exclusive,omitempty"`
+	MaxPriority int  `yaml:"maxPriority,omitempty"`
+	//settings that can also be changed by a policy
+	MessageTTL string `yaml:"messageTTL,omitempty"`
+	MaxLength  int    `yaml:"maxLength,omitempty"`
+	Overflow   string `yaml:"overflow,omitempty"`
+	Lazy       bool   `yaml:"lazy,omitempty"`
+	ApplyAs    string `yaml:"applyAs,omitempty"`
+}
+
+//returns the vhost of the queue, otherwise the vhost of its host or / if neither is set
+func (c *QueueConfigs) VhostName(hostVhost string) string {
+	if c != nil && c.Vhost != "" {
+		return c.Vhost
+	}
+	if hostVhost != "" {
+		return hostVhost
+	}
+	return "/"
+}
+
+//returns the type of the queue, classic if none is set
+func (c *QueueConfigs) QueueType() string {
+	if c == nil || c.Type == "" {
+		return QueueTypeClassic
+	}
+	return c.Type
+}
+
+//returns true if the queue survives a restart of the broker, which is the default
+func (c *QueueConfigs) IsDurable() bool {
+	return c == nil || c.Durable == nil || *c.Durable
+}
+
+//returns true if the runtime settings are applied with a policy
+func (c *QueueConfigs) UsesPolicy() bool {
+	return c != nil && (c.ApplyAs == "" || c.ApplyAs == ApplyAsPolicy)
+}
+
+//checks the values of the settings and whether the type of the queue supports them
+func (c *QueueConfigs) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	switch c.ApplyAs {
+	case "", ApplyAsPolicy, ApplyAsArguments:
+	default:
+		return fmt.Errorf("invalid applyAs %s, expected %s or %s", c.ApplyAs, ApplyAsPolicy, ApplyAsArguments)
+	}
+	if c.MaxPriority < 0 || c.MaxPriority > 255 {
+		return fmt.Errorf("invalid maxPriority %d, expected 1 to 255", c.MaxPriority)
+	}
+	if c.MaxLength < 0 {
+		return fmt.Errorf("invalid maxLength %d", c.MaxLength)
+	}
+	if c.Overflow != "" && !containsString(QueueOverflowModes, c.Overflow) {
+		return fmt.Errorf("invalid overflow %s, expected one of %s", c.Overflow, strings.Join(QueueOverflowModes, ", "))
+	}
+	if c.MessageTTL != "" {
+		if _, err := c.MessageTTLMilliseconds(); err != nil {
+			return err
+		}
+	}
+
+	switch c.QueueType() {
+	case QueueTypeClassic:
+	case QueueTypeQuorum, QueueTypeStream:
+		//replicated queues are always durable and cannot belong to a single connection
+		if !c.IsDurable() || c.AutoDelete || c.Exclusive {
+			return fmt.Errorf("%s queues must be durable and cannot be autoDelete or exclusive", c.Type)
+		}
+		if c.MaxPriority > 0 || c.Lazy {
+			return fmt.Errorf("%s queues do not support maxPriority or lazy", c.Type)
+		}
+		if c.Type == QueueTypeQuorum && c.Overflow == "reject-publish-dlx" {
+			return fmt.Errorf("quorum queues do not support overflow reject-publish-dlx")
+		}
+		if c.Type == QueueTypeStream && (c.MessageTTL != "" || c.MaxLength > 0 || c.Overflow != "") {
+			return fmt.Errorf("stream queues do not support messageTTL, maxLength or overflow")
+		}
+	default:
+		return fmt.Errorf("invalid queue type %s, expected %s, %s or %s", c.Type, QueueTypeClassic, QueueTypeQuorum, QueueTypeStream)
+	}
+	return nil
+}
+
+//returns the message TTL in milliseconds, plain numbers are taken as milliseconds
+func (c *QueueConfigs) MessageTTLMilliseconds() (int64, error) {
+	value := strings.TrimSpace(c.MessageTTL)
+	milliseconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		duration, durationErr := time.ParseDuration(value)
+		if durationErr != nil {
+			return 0, fmt.Errorf("%q is not a messageTTL (e.g. 500ms, 30s, 1h or milliseconds)", c.MessageTTL)
+		}
+		milliseconds = duration.Milliseconds()
+	}
+	if milliseconds < 0 {
+		return 0, fmt.Errorf("invalid messageTTL %q", c.MessageTTL)
+	}
+	return milliseconds, nil
+}
+
+//returns the arguments of the queue declaration, the runtime settings only if they are not applied with a policy
+func (c *QueueConfigs) Arguments() map[string]interface{} {
+	arguments := make(map[string]interface{})
+	if c == nil {
+		return arguments
+	}
+	if c.Type != "" {
+		arguments["x-queue-type"] = c.Type
+	}
+	if c.MaxPriority > 0 {
+		arguments["x-max-priority"] = c.MaxPriority
+	}
+	if !c.UsesPolicy() {
+		for key, value := range c.runtimeSettings() {
+			arguments["x-"+key] = value
+		}
+	}
+	return arguments
+}
+
+//returns the definition of the policy of the queue, empty if the runtime settings are passed as arguments
+func (c *QueueConfigs) PolicyDefinition() map[string]interface{} {
+	if !c.UsesPolicy() {
+		return map[string]interface{}{}
+	}
+	return c.runtimeSettings()
+}
+
+//returns the settings that RabbitMQ allows to change with a policy, named as in a policy definition
+func (c *QueueConfigs) runtimeSettings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	if c.MessageTTL != "" {
+		ttl, _ := c.MessageTTLMilliseconds()
+		settings["message-ttl"] = ttl
+	}
+	if c.MaxLength > 0 {
+		settings["max-length"] = c.MaxLength
+	}
+	if c.Overflow != "" {
+		settings["overflow"] = c.Overflow
+	}
+	if c.Lazy {
+		settings["queue-mode"] = "lazy"
+	}
+	return settings
+}
+
+//returns a compact description of the set settings, e.g. for the changes of a plan
+func (c *QueueConfigs) String() string {
+	if c == nil {
+		return ""
+	}
+	var parts []string
+	add := func(name string, value interface{}, set bool) {
+		if set {
+			parts = append(parts, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+	add("vhost", c.Vhost, c.Vhost != "")
+	add("type", c.Type, c.Type != "")
+	add("durable", c.IsDurable(), c.Durable != nil)
+	add("autoDelete", c.AutoDelete, c.AutoDelete)
+	add("exclusive", c.Exclusive, c.Exclusive)
+	add("maxPriority", c.MaxPriority, c.MaxPriority > 0)
+	add("messageTTL", c.MessageTTL, c.MessageTTL != "")
+	add("maxLength", c.MaxLength, c.MaxLength > 0)
+	add("overflow", c.Overflow, c.Overflow != "")
+	add("lazy", c.Lazy, c.Lazy)
+	add("applyAs", c.ApplyAs, c.ApplyAs != "")
+	return strings.Join(parts, ", ")
+}
+
+func containsString(slice []string, item string) bool {
+	for _, value := range slice {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+//prefix of host properties that reference a secret instead of holding the value
+const SecretPrefix = "secret:"
+
+//sources a secret reference can point to
+const (
+	//environment variable of the EICODA process, e.g. secret:env:RABBITMQ_PASSWORD
+	SecretSourceEnv = "env"
+	//file relative to the deployment model, e.g. secret:file:secrets/rabbitmq-password
+	SecretSourceFile = "file"
+	//secret managed outside of EICODA, e.g. secret:external:rabbitmq-credentials/password
+	SecretSourceExternal = "external"
+)
+
+//replaces credentials that are stored in plaintext when a model is recorded
+const RedactedValue = "(redacted)"
+
+//host properties holding credentials, they are never written into generated files
+var CredentialProps = []string{"username", "password"}
+
+//references a secret value that is resolved when the model is deployed
+type SecretRef struct {
+	Source string
+	//name of the environment variable, path of the file or name of the external secret
+	Name string
+	//key inside an external secret
+	Key string
+}
+
+//a resolved credential the plugins provide to the target platforms, it is never written to a file
+type Secret struct {
+	//environment variable the generated models reference the value with
+	EnvName string
+	//kubernetes secret and key holding the value
+	SecretName string
+	Key        string
+	Value      string
+}
+
+//parses a secret reference, returns nil if the value is no reference
+func ParseSecretRef(value string) (*SecretRef, error) {
+	if !strings.HasPrefix(value, SecretPrefix) {
+		return nil, nil
+	}
+
+	source, name, found := strings.Cut(strings.TrimPrefix(value, SecretPrefix), ":")
+	if !found || name == "" {
+		return nil, fmt.Errorf("invalid secret reference %s, expected secret:<source>:<name>", value)
+	}
+
+	switch source {
+	case SecretSourceEnv, SecretSourceFile:
+		return &SecretRef{Source: source, Name: name}, nil
+	case SecretSourceExternal:
+		secretName, key, found := strings.Cut(name, "/")
+		if !found || secretName == "" || key == "" {
+			return nil, fmt.Errorf("invalid external secret reference %s, expected secret:external:<name>/<key>", value)
+		}
+		return &SecretRef{Source: source, Name: secretName, Key: key}, nil
+	default:
+		return nil, fmt.Errorf("unknown secret source %s in %s, expected env, file or external", source, value)
+	}
+}
+
+//returns true if the host property holds a credential
+func IsCredentialProp(name string) bool {
+	for _, prop := range CredentialProps {
+		if prop == name {
+			return true
+		}
+	}
+	return false
+}
+
+//returns a copy of the model in which credentials stored in plaintext are replaced, secret references are kept
+func RedactCredentials(model *Model) *Model {
+	if model == nil {
+		return nil
+	}
+	redacted := *model
+	redacted.Hosts.PipeHosts = redactHosts(model.Hosts.PipeHosts)
+	redacted.Hosts.FilterHosts = redactHosts(model.Hosts.FilterHosts)
+	return &redacted
+}
+
+func redactHosts(hosts []Host) []Host {
+	var result []Host
+	for _, host := range hosts {
+		props := make(map[string]string, len(host.AdditionalProps))
+		for key, value := range host.AdditionalProps {
+			if IsCredentialProp(key) && value != "" && !strings.HasPrefix(value, SecretPrefix) {
+				value = RedactedValue
+			}
+			props[key] = value
+		}
+		host.AdditionalProps = props
+		result = append(result, host)
+	}
+	return result
+}
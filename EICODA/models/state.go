@@ -0,0 +1,43 @@
+package models
+
+//status values of a deployment record and of the plugins executed for it
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusUnchanged = "unchanged"
+	StatusDestroyed = "destroyed"
+)
+
+//represents one recorded deploy or destroy run in the state store
+type DeploymentRecord struct {
+	ID        string           `yaml:"id"`
+	Name      string           `yaml:"name"`
+	Action    string           `yaml:"action"`
+	Timestamp string           `yaml:"timestamp"`
+	ModelPath string           `yaml:"modelPath,omitempty"`
+	Profiles  []string         `yaml:"profiles,omitempty"`
+	ModelHash string           `yaml:"modelHash,omitempty"`
+	//hash of the resolved credentials, used to detect changed credentials without recording them
+	SecretsHash string         `yaml:"secretsHash,omitempty"`
+	Status    string           `yaml:"status"`
+	Error     string           `yaml:"error,omitempty"`
+	Artifacts []ArtifactRecord `yaml:"artifacts,omitempty"`
+	Plugins   []PluginRecord   `yaml:"plugins,omitempty"`
+	Model     *Model           `yaml:"model,omitempty"`
+}
+
+//represents a file generated by a transformator during a deployment
+type ArtifactRecord struct {
+	Transformator string `yaml:"transformator"`
+	Path          string `yaml:"path"`
+	Hash          string `yaml:"hash"`
+	Content       string `yaml:"content"`
+}
+
+//represents the outcome of a plugin execution during a deployment
+type PluginRecord struct {
+	Name   string `yaml:"name"`
+	Status string `yaml:"status"`
+	Error  string `yaml:"error,omitempty"`
+}
@@ -4,20 +4,26 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
 type DockerComposePlugin struct{}
 
-func (p *DockerComposePlugin) Execute() error {
-	dockerComposeModelPath := filepath.Join("docker-compose.yaml")
+func (p *DockerComposePlugin) Execute(options ExecutionOptions) error {
+	dockerComposeModelPath := options.ModelPath("docker-compose.yaml")
 
 	if _, err := exec.Command("test", "-f", dockerComposeModelPath).Output(); err != nil {
 		return fmt.Errorf("docker-compose.yaml file not found: %w", err)
 	}
 
-	cmd := exec.Command("docker-compose", "-f", dockerComposeModelPath, "up", "-d", "--pull", "always")
+	//docker-compose only recreates services whose configuration changed, removed filters are dropped as orphans
+	pullPolicy := "always"
+	if options.Incremental {
+		pullPolicy = "missing"
+	}
+	cmd := exec.Command("docker-compose", "-p", options.ProjectName(), "-f", dockerComposeModelPath, "up", "-d", "--remove-orphans", "--pull", pullPolicy)
+	//the credentials in the connection strings are interpolated from the environment
+	cmd.Env = options.SecretEnv()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to apply docker-compose.yaml: %w, output: %s", err, string(output))
@@ -27,15 +33,16 @@ func (p *DockerComposePlugin) Execute() error {
 	return nil
 }
 
-func (p *DockerComposePlugin) Destroy() error {
-	dockerComposeModelPath := filepath.Join("docker-compose.yaml")
+func (p *DockerComposePlugin) Destroy(options ExecutionOptions) error {
+	dockerComposeModelPath := options.ModelPath("docker-compose.yaml")
 
 	if _, err := os.Stat(dockerComposeModelPath); os.IsNotExist(err) {
 		fmt.Println("docker-compose.yaml file not found. Skipping destruction process.")
 		return nil
 	}
 
-	cmd := exec.Command("docker-compose", "-f", dockerComposeModelPath, "down", "--rmi", "all", "--volumes", "--remove-orphans")
+	cmd := exec.Command("docker-compose", "-p", options.ProjectName(), "-f", dockerComposeModelPath, "down", "--rmi", "all", "--volumes", "--remove-orphans")
+	cmd.Env = options.SecretEnv()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		//handles cases where the services or containers might not exist
@@ -1,28 +1,56 @@
 package plugins
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
+//label of the secrets holding the credentials of a deployment, they do not carry the labels of the
+//generated resources so that pruning the applied model keeps them
+const secretsLabel = "eicoda.secrets"
+
+
 type KubernetesPlugin struct{}
 
-func (p *KubernetesPlugin) Execute() error {
-	kubernetesModelPath := filepath.Join(".", "kubernetesModel.yaml")
+//selects all resources generated by EICODA for the deployment
+func deploymentSelector(options ExecutionOptions) string {
+	return fmt.Sprintf("app.kubernetes.io/managed-by=eicoda,eicoda.deployment=%s", options.DeploymentName)
+}
+
+func (p *KubernetesPlugin) Execute(options ExecutionOptions) error {
+	kubernetesModelPath := options.ModelPath("kubernetesModel.yaml")
 
 	if _, err := exec.Command("test", "-f", kubernetesModelPath).Output(); err != nil {
 		return fmt.Errorf("kubernetesModel.yaml file not found: %w", err)
 	}
 
-	cmd := exec.Command("kubectl", "apply", "-f", kubernetesModelPath)
+	if err := applySecrets(options); err != nil {
+		return err
+	}
+
+	//pruning deletes deployments and config maps of filters that were removed from the model
+	args := []string{"apply", "-f", kubernetesModelPath}
+	if options.Incremental {
+		args = append(args, "--prune", "-l", deploymentSelector(options))
+	}
+	cmd := exec.Command("kubectl", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to apply kubernetesModel.yaml: %w, output: %s", err, string(output))
 	}
 
+	//pods read secrets only on start, so changed credentials need a rollout even if the model is unchanged
+	if options.Incremental && options.SecretsChanged {
+		restartCmd := exec.Command("kubectl", "rollout", "restart", "deployment", "-l", deploymentSelector(options))
+		restartOutput, err := restartCmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to restart deployments after credential change: %w, output: %s", err, string(restartOutput))
+		}
+	}
+
 	checkCmd := exec.Command("kubectl", "get", "deployments")
 	checkOutput, err := checkCmd.CombinedOutput()
 	if err != nil {
@@ -33,14 +61,19 @@ func (p *KubernetesPlugin) Execute() error {
 	return nil
 }
 
-func (p *KubernetesPlugin) Destroy() error {
-	kubernetesModelPath := filepath.Join(".", "kubernetesModel.yaml")
+func (p *KubernetesPlugin) Destroy(options ExecutionOptions) error {
+	kubernetesModelPath := options.ModelPath("kubernetesModel.yaml")
 
 	if _, err := os.Stat(kubernetesModelPath); os.IsNotExist(err) {
 		fmt.Println("kubernetesModel.yaml file not found. Skipping destruction process.")
 		return nil
 	}
 
+	secretsCmd := exec.Command("kubectl", "delete", "secret", "-l", fmt.Sprintf("%s=%s", secretsLabel, options.DeploymentName), "--ignore-not-found")
+	if secretsOutput, err := secretsCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete credential secrets: %w, output: %s", err, string(secretsOutput))
+	}
+
 	cmd := exec.Command("kubectl", "delete", "-f", kubernetesModelPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -55,3 +88,51 @@ func (p *KubernetesPlugin) Destroy() error {
 
 	return nil
 }
+
+//creates or updates the secrets referenced by the generated model. The manifest is passed to kubectl on stdin,
+//so the credentials are never written to a file
+func applySecrets(options ExecutionOptions) error {
+	if len(options.Secrets) == 0 {
+		return nil
+	}
+
+	var names []string
+	data := make(map[string]map[string]string)
+	for _, secret := range options.Secrets {
+		if _, exists := data[secret.SecretName]; !exists {
+			names = append(names, secret.SecretName)
+			data[secret.SecretName] = make(map[string]string)
+		}
+		data[secret.SecretName][secret.Key] = secret.Value
+	}
+
+	var items []map[string]interface{}
+	for _, name := range names {
+		items = append(items, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"type":       "Opaque",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]string{secretsLabel: options.DeploymentName},
+			},
+			"stringData": data[name],
+		})
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential secrets: %w", err)
+	}
+
+	cmd := exec.Command("kubectl", "apply", "-f", "-")
+	cmd.Stdin = strings.NewReader(string(manifest))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to apply credential secrets: %w, output: %s", err, string(output))
+	}
+	return nil
+}
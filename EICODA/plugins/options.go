@@ -0,0 +1,46 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"eicoda/models"
+)
+
+//holds settings that control how a plugin applies a generated model
+type ExecutionOptions struct {
+	//set when a previous deployment exists, plugins then only update changed resources and keep the rest running
+	Incremental bool
+	//identity of the deployment, used as compose project name and kubernetes label
+	DeploymentName string
+	//directory holding the generated models and the terraform state of the deployment
+	WorkDir string
+	//resolved credentials the generated models reference, they are only passed to the tools and never written to a file
+	Secrets []models.Secret
+	//set when the credentials changed since the previous deployment, running filters then have to be restarted
+	SecretsChanged bool
+}
+
+//returns the environment of the tools with the credentials added, once under the name the compose model
+//references and once as TF_VAR_ variable for terraform
+func (o ExecutionOptions) SecretEnv() []string {
+	env := os.Environ()
+	for _, secret := range o.Secrets {
+		env = append(env,
+			secret.EnvName+"="+secret.Value,
+			"TF_VAR_"+strings.ToLower(secret.EnvName)+"="+secret.Value,
+		)
+	}
+	return env
+}
+
+//returns the path of a generated model inside the working directory of the deployment
+func (o ExecutionOptions) ModelPath(fileName string) string {
+	return filepath.Join(o.WorkDir, fileName)
+}
+
+//returns the docker compose project name of the deployment
+func (o ExecutionOptions) ProjectName() string {
+	return "eicoda-" + o.DeploymentName
+}
@@ -0,0 +1,508 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"eicoda/models"
+	"eicoda/transformators"
+)
+
+//file in the working directory holding the definitions the plugin applied last, like the terraform state it
+//tells which resources were removed from the model and what a destroy has to delete
+const rabbitMqManagementStateFile = "rabbitMqManagementState.json"
+
+//defaults of the retries of a request, the delay doubles with every attempt
+const (
+	defaultManagementRetries    = 3
+	defaultManagementRetryDelay = time.Second
+)
+
+//provisions the vhosts, queues, exchanges, bindings and policies of the RabbitMQ hosts through the management HTTP API
+//without terraform. Declarations are idempotent, so applying the same definitions again changes nothing. Like in the
+//Terraform model, vhosts other than / are created with all permissions for the user of the host and deleted again
+//with the deployment, users are not managed
+type RabbitMqManagementPlugin struct {
+	//overrides the management endpoints of all hosts, e.g. to provision against a local test server
+	BaseURL string
+	//client the requests are sent with, a client with a timeout of 30 seconds if none is set
+	Client *http.Client
+	//attempts after a failed request and the delay before the first of them
+	Retries    int
+	RetryDelay time.Duration
+}
+
+//a request to the management API of a host with its credentials
+type managementTarget struct {
+	host     string
+	endpoint string
+	username string
+	password string
+}
+
+//an error response of the management API
+type managementError struct {
+	status int
+	body   string
+}
+
+func (e *managementError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, strings.TrimSpace(e.body))
+}
+
+func (p *RabbitMqManagementPlugin) Execute(options ExecutionOptions) error {
+	hosts, err := readRabbitMqDefinitions(options.ModelPath(transformators.RabbitMqDefinitionsModelFile))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s file not found: %w", transformators.RabbitMqDefinitionsModelFile, err)
+	}
+	if err != nil {
+		return err
+	}
+	applied, err := readRabbitMqDefinitions(options.ModelPath(rabbitMqManagementStateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, name := range sortedHostNames(hosts) {
+		target, err := p.target(name, hosts[name].Endpoint, options)
+		if err != nil {
+			return err
+		}
+		if err := p.declare(target, hosts[name].Definitions); err != nil {
+			return fmt.Errorf("failed to provision RabbitMQ host %s: %w", name, err)
+		}
+		//resources the model no longer contains are deleted after the new ones exist, so filters can switch over
+		if previous, exists := applied[name]; exists {
+			if err := p.delete(target, removedDefinitions(previous.Definitions, hosts[name].Definitions)); err != nil {
+				return fmt.Errorf("failed to remove resources of RabbitMQ host %s: %w", name, err)
+			}
+		}
+	}
+
+	//hosts that are no longer part of the model lose all resources of the deployment
+	for _, name := range sortedHostNames(applied) {
+		if _, exists := hosts[name]; exists {
+			continue
+		}
+		target, err := p.target(name, applied[name].Endpoint, options)
+		if err != nil {
+			return err
+		}
+		if err := p.delete(target, applied[name].Definitions); err != nil {
+			return fmt.Errorf("failed to remove resources of RabbitMQ host %s: %w", name, err)
+		}
+	}
+
+	if err := writeRabbitMqManagementState(options.ModelPath(rabbitMqManagementStateFile), hosts); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully provisioned %d RabbitMQ host(s) through the management API.\n", len(hosts))
+	return nil
+}
+
+func (p *RabbitMqManagementPlugin) Destroy(options ExecutionOptions) error {
+	statePath := options.ModelPath(rabbitMqManagementStateFile)
+	applied, err := readRabbitMqDefinitions(statePath)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s file not found. Skipping destruction process.\n", rabbitMqManagementStateFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedHostNames(applied) {
+		target, err := p.target(name, applied[name].Endpoint, options)
+		if err != nil {
+			return err
+		}
+		if err := p.delete(target, applied[name].Definitions); err != nil {
+			return fmt.Errorf("failed to destroy resources of RabbitMQ host %s: %w", name, err)
+		}
+	}
+
+	if err := os.Remove(statePath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", statePath, err)
+	}
+	fmt.Println("Successfully destroyed RabbitMQ resources through the management API.")
+	return nil
+}
+
+//returns the endpoint and credentials of a host, the credentials are passed like to the terraform provider
+func (p *RabbitMqManagementPlugin) target(host string, endpoint string, options ExecutionOptions) (managementTarget, error) {
+	target := managementTarget{host: host, endpoint: strings.TrimRight(endpoint, "/")}
+	if p.BaseURL != "" {
+		target.endpoint = strings.TrimRight(p.BaseURL, "/")
+	}
+
+	//without a recorded model, e.g. when a failed destroy is repeated, the credentials come from the environment
+	usernameEnv := transformators.SecretEnvName(&models.Host{Name: host}, "username")
+	passwordEnv := transformators.SecretEnvName(&models.Host{Name: host}, "password")
+	var hasUsername, hasPassword bool
+	target.username, hasUsername = os.LookupEnv(usernameEnv)
+	target.password, hasPassword = os.LookupEnv(passwordEnv)
+	for _, secret := range options.Secrets {
+		switch secret.EnvName {
+		case usernameEnv:
+			target.username, hasUsername = secret.Value, true
+		case passwordEnv:
+			target.password, hasPassword = secret.Value, true
+		}
+	}
+	if !hasUsername || !hasPassword {
+		return target, fmt.Errorf("the credentials of RabbitMQ host %s are not available, set %s and %s", host, usernameEnv, passwordEnv)
+	}
+	return target, nil
+}
+
+//declares the vhosts first, then the exchanges and queues before the bindings that reference them, policies last
+func (p *RabbitMqManagementPlugin) declare(target managementTarget, definitions transformators.RabbitMqDefinitions) error {
+	//the vhost / always exists and is not managed
+	for _, vhost := range definitions.Vhosts {
+		if vhost.Name == "/" {
+			continue
+		}
+		if err := p.request(target, http.MethodPut, "/api/vhosts/"+url.PathEscape(vhost.Name), map[string]interface{}{}); err != nil {
+			return fmt.Errorf("vhost %s: %w", vhost.Name, err)
+		}
+		body := map[string]interface{}{
+			"configure": ".*",
+			"write":     ".*",
+			"read":      ".*",
+		}
+		if err := p.request(target, http.MethodPut, managementPath("permissions", vhost.Name, target.username), body); err != nil {
+			return fmt.Errorf("permissions of %s in vhost %s: %w", target.username, vhost.Name, err)
+		}
+	}
+
+	for _, exchange := range definitions.Exchanges {
+		body := map[string]interface{}{
+			"type":        exchange.Type,
+			"durable":     exchange.Durable,
+			"auto_delete": exchange.AutoDelete,
+			"internal":    exchange.Internal,
+			"arguments":   nonNilArguments(exchange.Arguments),
+		}
+		if err := p.request(target, http.MethodPut, managementPath("exchanges", exchange.Vhost, exchange.Name), body); err != nil {
+			return fmt.Errorf("exchange %s: %w", exchange.Name, err)
+		}
+	}
+
+	//RabbitMQ rejects a declaration that differs from an existing queue, recreating it would lose its messages
+	for _, queue := range definitions.Queues {
+		body := map[string]interface{}{
+			"durable":     queue.Durable,
+			"auto_delete": queue.AutoDelete,
+			"arguments":   nonNilArguments(queue.Arguments),
+		}
+		if err := p.request(target, http.MethodPut, managementPath("queues", queue.Vhost, queue.Name), body); err != nil {
+			if apiErr, ok := err.(*managementError); ok && apiErr.status == http.StatusBadRequest {
+				return fmt.Errorf("queue %s exists with different settings, delete it to apply the new ones: %w", queue.Name, err)
+			}
+			return fmt.Errorf("queue %s: %w", queue.Name, err)
+		}
+	}
+
+	//RabbitMQ identifies a binding by its routing key and arguments, so posting an existing binding adds no duplicate
+	for _, binding := range definitions.Bindings {
+		body := map[string]interface{}{
+			"routing_key": binding.RoutingKey,
+			"arguments":   nonNilArguments(binding.Arguments),
+		}
+		if err := p.request(target, http.MethodPost, bindingPath(binding), body); err != nil {
+			return fmt.Errorf("binding %s -> %s: %w", binding.Source, binding.Destination, err)
+		}
+	}
+
+	for _, policy := range definitions.Policies {
+		body := map[string]interface{}{
+			"pattern":    policy.Pattern,
+			"apply-to":   policy.ApplyTo,
+			"definition": policy.Definition,
+			"priority":   policy.Priority,
+		}
+		if err := p.request(target, http.MethodPut, managementPath("policies", policy.Vhost, policy.Name), body); err != nil {
+			return fmt.Errorf("policy %s: %w", policy.Name, err)
+		}
+	}
+	return nil
+}
+
+//deletes the given resources, resources that no longer exist are skipped. Bindings go first, deleting a queue or
+//exchange also deletes its bindings, vhosts last
+func (p *RabbitMqManagementPlugin) delete(target managementTarget, definitions transformators.RabbitMqDefinitions) error {
+	for _, binding := range definitions.Bindings {
+		if err := p.deleteBinding(target, binding); err != nil {
+			return fmt.Errorf("binding %s -> %s: %w", binding.Source, binding.Destination, err)
+		}
+	}
+	for _, policy := range definitions.Policies {
+		if err := p.request(target, http.MethodDelete, managementPath("policies", policy.Vhost, policy.Name), nil); err != nil {
+			return fmt.Errorf("policy %s: %w", policy.Name, err)
+		}
+	}
+	for _, queue := range definitions.Queues {
+		if err := p.request(target, http.MethodDelete, managementPath("queues", queue.Vhost, queue.Name), nil); err != nil {
+			return fmt.Errorf("queue %s: %w", queue.Name, err)
+		}
+	}
+	for _, exchange := range definitions.Exchanges {
+		if err := p.request(target, http.MethodDelete, managementPath("exchanges", exchange.Vhost, exchange.Name), nil); err != nil {
+			return fmt.Errorf("exchange %s: %w", exchange.Name, err)
+		}
+	}
+	for _, vhost := range definitions.Vhosts {
+		if vhost.Name == "/" {
+			continue
+		}
+		if err := p.request(target, http.MethodDelete, "/api/vhosts/"+url.PathEscape(vhost.Name), nil); err != nil {
+			return fmt.Errorf("vhost %s: %w", vhost.Name, err)
+		}
+	}
+	return nil
+}
+
+//bindings are deleted by their properties key, which RabbitMQ derives from the routing key and arguments, so it is
+//looked up among the bindings between the source and destination
+func (p *RabbitMqManagementPlugin) deleteBinding(target managementTarget, binding transformators.RabbitMqBindingDefinition) error {
+	var existing []struct {
+		RoutingKey    string                 `json:"routing_key"`
+		Arguments     map[string]interface{} `json:"arguments"`
+		PropertiesKey string                 `json:"properties_key"`
+	}
+	found, err := p.get(target, bindingPath(binding), &existing)
+	if err != nil || !found {
+		return err
+	}
+
+	wanted := normalizeArguments(binding.Arguments)
+	for _, candidate := range existing {
+		if candidate.RoutingKey != binding.RoutingKey || !reflect.DeepEqual(normalizeArguments(candidate.Arguments), wanted) {
+			continue
+		}
+		return p.request(target, http.MethodDelete, bindingPath(binding)+"/"+url.PathEscape(candidate.PropertiesKey), nil)
+	}
+	return nil
+}
+
+//sends a request and retries it on connection errors and server side failures. Not found is no error for a
+//delete, since a deleted resource is what it wants
+func (p *RabbitMqManagementPlugin) request(target managementTarget, method string, path string, body interface{}) error {
+	_, err := p.send(target, method, path, body, nil)
+	return err
+}
+
+//reads a resource into result and returns false if it does not exist
+func (p *RabbitMqManagementPlugin) get(target managementTarget, path string, result interface{}) (bool, error) {
+	return p.send(target, http.MethodGet, path, nil, result)
+}
+
+func (p *RabbitMqManagementPlugin) send(target managementTarget, method string, path string, body interface{}, result interface{}) (bool, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	retries, delay := p.Retries, p.RetryDelay
+	if retries <= 0 {
+		retries = defaultManagementRetries
+	}
+	if delay <= 0 {
+		delay = defaultManagementRetryDelay
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		found, retry, err := p.sendOnce(target, method, path, payload, result)
+		if !retry {
+			return found, err
+		}
+		lastErr = err
+	}
+	return false, fmt.Errorf("%s %s failed after %d attempts: %w", method, path, retries+1, lastErr)
+}
+
+//sends a request once and tells whether a failure is worth another attempt
+func (p *RabbitMqManagementPlugin) sendOnce(target managementTarget, method string, path string, payload []byte, result interface{}) (bool, bool, error) {
+	request, err := http.NewRequest(method, target.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, false, fmt.Errorf("invalid request to host %s: %w", target.host, err)
+	}
+	request.SetBasicAuth(target.username, target.password)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, true, err
+	}
+	defer response.Body.Close()
+	content, _ := io.ReadAll(response.Body)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound && (method == http.MethodGet || method == http.MethodDelete):
+		return false, false, nil
+	case response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests:
+		return false, true, &managementError{status: response.StatusCode, body: string(content)}
+	case response.StatusCode >= 300:
+		return false, false, &managementError{status: response.StatusCode, body: string(content)}
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(content, result); err != nil {
+			return false, false, fmt.Errorf("failed to decode response of %s: %w", path, err)
+		}
+	}
+	return true, false, nil
+}
+
+//returns the path of a queue, exchange, policy or the permissions of a user, the default vhost / is escaped as %2F
+func managementPath(kind string, vhost string, name string) string {
+	return fmt.Sprintf("/api/%s/%s/%s", kind, url.PathEscape(vhost), url.PathEscape(name))
+}
+
+//returns the path of the bindings between the exchange of a binding and its queue or exchange
+func bindingPath(binding transformators.RabbitMqBindingDefinition) string {
+	destinationType := "q"
+	if binding.DestinationType == "exchange" {
+		destinationType = "e"
+	}
+	return fmt.Sprintf("/api/bindings/%s/e/%s/%s/%s", url.PathEscape(binding.Vhost), url.PathEscape(binding.Source),
+		destinationType, url.PathEscape(binding.Destination))
+}
+
+//returns the resources of the applied definitions that the new ones no longer contain
+func removedDefinitions(applied transformators.RabbitMqDefinitions, current transformators.RabbitMqDefinitions) transformators.RabbitMqDefinitions {
+	var removed transformators.RabbitMqDefinitions
+
+	queues := make(map[string]bool)
+	for _, queue := range current.Queues {
+		queues[queue.Vhost+"/"+queue.Name] = true
+	}
+	for _, queue := range applied.Queues {
+		if !queues[queue.Vhost+"/"+queue.Name] {
+			removed.Queues = append(removed.Queues, queue)
+		}
+	}
+
+	exchanges := make(map[string]bool)
+	for _, exchange := range current.Exchanges {
+		exchanges[exchange.Vhost+"/"+exchange.Name] = true
+	}
+	for _, exchange := range applied.Exchanges {
+		if !exchanges[exchange.Vhost+"/"+exchange.Name] {
+			removed.Exchanges = append(removed.Exchanges, exchange)
+		}
+	}
+
+	vhosts := make(map[string]bool)
+	for _, vhost := range current.Vhosts {
+		vhosts[vhost.Name] = true
+	}
+	for _, vhost := range applied.Vhosts {
+		if !vhosts[vhost.Name] {
+			removed.Vhosts = append(removed.Vhosts, vhost)
+		}
+	}
+
+	policies := make(map[string]bool)
+	for _, policy := range current.Policies {
+		policies[policy.Vhost+"/"+policy.Name] = true
+	}
+	for _, policy := range applied.Policies {
+		if !policies[policy.Vhost+"/"+policy.Name] {
+			removed.Policies = append(removed.Policies, policy)
+		}
+	}
+
+	for _, binding := range applied.Bindings {
+		exists := false
+		for _, candidate := range current.Bindings {
+			if candidate.Vhost == binding.Vhost && candidate.Source == binding.Source && candidate.Destination == binding.Destination &&
+				candidate.DestinationType == binding.DestinationType && candidate.RoutingKey == binding.RoutingKey &&
+				reflect.DeepEqual(normalizeArguments(candidate.Arguments), normalizeArguments(binding.Arguments)) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			removed.Bindings = append(removed.Bindings, binding)
+		}
+	}
+	return removed
+}
+
+//the management API expects an object even without arguments
+func nonNilArguments(arguments map[string]interface{}) map[string]interface{} {
+	if arguments == nil {
+		return map[string]interface{}{}
+	}
+	return arguments
+}
+
+//makes arguments comparable regardless of how they were decoded, e.g. numbers become float64
+func normalizeArguments(arguments map[string]interface{}) map[string]interface{} {
+	normalized := map[string]interface{}{}
+	data, _ := json.Marshal(nonNilArguments(arguments))
+	json.Unmarshal(data, &normalized)
+	return normalized
+}
+
+func readRabbitMqDefinitions(path string) (map[string]transformators.RabbitMqHostDefinitions, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	hosts := make(map[string]transformators.RabbitMqHostDefinitions)
+	if err := json.Unmarshal(content, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return hosts, nil
+}
+
+func writeRabbitMqManagementState(path string, hosts map[string]transformators.RabbitMqHostDefinitions) error {
+	content, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode RabbitMQ management state: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func sortedHostNames(hosts map[string]transformators.RabbitMqHostDefinitions) []string {
+	var names []string
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
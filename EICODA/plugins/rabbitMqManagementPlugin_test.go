@@ -0,0 +1,422 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"eicoda/models"
+	"eicoda/transformators"
+)
+
+//an in memory management API that behaves like RabbitMQ for the requests of the plugin
+type fakeManagementAPI struct {
+	mu        sync.Mutex
+	resources map[string]map[string]interface{}
+	bindings  map[string][]map[string]interface{}
+	requests  []string
+	//number of requests answered with 503 before the API becomes available
+	unavailable int
+}
+
+func newFakeManagementAPI() *fakeManagementAPI {
+	return &fakeManagementAPI{
+		resources: make(map[string]map[string]interface{}),
+		bindings:  make(map[string][]map[string]interface{}),
+	}
+}
+
+func (api *fakeManagementAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	api.requests = append(api.requests, r.Method+" "+path)
+	if api.unavailable > 0 {
+		api.unavailable--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != "guest" || password != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body map[string]interface{}
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	//resources of a vhost other than / can only be declared once the vhost exists
+	parts := strings.Split(path, "/")
+	if !strings.HasPrefix(path, "/api/vhosts/") && parts[3] != "%2F" && !api.hasVhost(parts[3]) {
+		http.Error(w, `{"error":"not_found","reason":"vhost not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if strings.HasPrefix(path, "/api/bindings/") {
+		api.serveBindings(w, r.Method, path, body)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		//queues cannot be redeclared with other settings
+		if existing, exists := api.resources[path]; exists && strings.HasPrefix(path, "/api/queues/") && !reflect.DeepEqual(existing, body) {
+			http.Error(w, `{"error":"bad_request","reason":"inequivalent arg 'durable'"}`, http.StatusBadRequest)
+			return
+		}
+		api.resources[path] = body
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, exists := api.resources[path]; !exists {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		delete(api.resources, path)
+		//deleting a vhost deletes everything in it
+		if strings.HasPrefix(path, "/api/vhosts/") {
+			api.deleteVhostResources(parts[3])
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+//bindings are posted to the path of their source and destination and deleted by their properties key
+func (api *fakeManagementAPI) serveBindings(w http.ResponseWriter, method string, path string, body map[string]interface{}) {
+	switch method {
+	case http.MethodPost:
+		arguments, _ := json.Marshal(body["arguments"])
+		body["properties_key"] = body["routing_key"].(string) + "~" + string(arguments)
+		for _, existing := range api.bindings[path] {
+			if existing["properties_key"] == body["properties_key"] {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		api.bindings[path] = append(api.bindings[path], body)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(api.bindingsOf(path))
+	case http.MethodDelete:
+		index := strings.LastIndex(path, "/")
+		bindings := api.bindings[path[:index]]
+		for i, existing := range bindings {
+			if "/"+url.PathEscape(existing["properties_key"].(string)) == path[index:] {
+				api.bindings[path[:index]] = append(bindings[:i], bindings[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (api *fakeManagementAPI) hasVhost(vhost string) bool {
+	_, exists := api.resources["/api/vhosts/"+vhost]
+	return exists
+}
+
+func (api *fakeManagementAPI) deleteVhostResources(vhost string) {
+	for path := range api.resources {
+		if parts := strings.Split(path, "/"); parts[2] != "vhosts" && parts[3] == vhost {
+			delete(api.resources, path)
+		}
+	}
+	for path := range api.bindings {
+		if strings.Split(path, "/")[3] == vhost {
+			delete(api.bindings, path)
+		}
+	}
+}
+
+func (api *fakeManagementAPI) bindingsOf(path string) []map[string]interface{} {
+	bindings := api.bindings[path]
+	if bindings == nil {
+		return []map[string]interface{}{}
+	}
+	return bindings
+}
+
+func (api *fakeManagementAPI) has(path string) bool {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	_, exists := api.resources[path]
+	return exists
+}
+
+func (api *fakeManagementAPI) bindingCount(path string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return len(api.bindings[path])
+}
+
+//definitions of a host with a topic exchange, a queue bound to it and a policy
+func testDefinitions() transformators.RabbitMqDefinitions {
+	return transformators.RabbitMqDefinitions{
+		Exchanges: []transformators.RabbitMqExchangeDefinition{
+			{Name: "orders", Vhost: "/", Type: "topic", Durable: true},
+		},
+		Queues: []transformators.RabbitMqQueueDefinition{
+			{Name: "invoices", Vhost: "/", Durable: true},
+			{Name: "audit", Vhost: "/", Durable: true, Arguments: map[string]interface{}{"x-max-length": 1000}},
+		},
+		Bindings: []transformators.RabbitMqBindingDefinition{
+			{Source: "orders", Vhost: "/", Destination: "invoices", DestinationType: "queue", RoutingKey: "order.created"},
+			{Source: "orders", Vhost: "/", Destination: "audit", DestinationType: "queue", RoutingKey: "#"},
+		},
+		Policies: []transformators.RabbitMqPolicyDefinition{
+			{Name: "audit-ttl", Vhost: "/", Pattern: "^audit$", ApplyTo: "queues", Definition: map[string]interface{}{"message-ttl": 60000}},
+		},
+	}
+}
+
+//writes the definitions like the RabbitMQ definitions transformator and returns the options the plugin reads them with
+func writeTestDefinitions(t *testing.T, dir string, definitions transformators.RabbitMqDefinitions) ExecutionOptions {
+	t.Helper()
+	hosts := map[string]transformators.RabbitMqHostDefinitions{
+		"devRabbitMQ": {Endpoint: "http://localhost:15672", Definitions: definitions},
+	}
+	content, err := json.Marshal(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, transformators.RabbitMqDefinitionsModelFile), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ExecutionOptions{
+		DeploymentName: "test",
+		WorkDir:        dir,
+		Secrets: []models.Secret{
+			{EnvName: "EICODA_DEVRABBITMQ_USERNAME", Value: "guest"},
+			{EnvName: "EICODA_DEVRABBITMQ_PASSWORD", Value: "secret"},
+		},
+	}
+}
+
+func newTestPlugin(server *httptest.Server) *RabbitMqManagementPlugin {
+	return &RabbitMqManagementPlugin{BaseURL: server.URL, Client: server.Client(), Retries: 3, RetryDelay: time.Millisecond}
+}
+
+func TestExecuteDeclaresDefinitionsOnce(t *testing.T) {
+	api := newFakeManagementAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+	plugin := newTestPlugin(server)
+	options := writeTestDefinitions(t, t.TempDir(), testDefinitions())
+
+	//applying the same definitions again must not duplicate anything
+	for i := 0; i < 2; i++ {
+		if err := plugin.Execute(options); err != nil {
+			t.Fatalf("execute %d failed: %v", i+1, err)
+		}
+	}
+
+	for _, path := range []string{
+		"/api/exchanges/%2F/orders",
+		"/api/queues/%2F/invoices",
+		"/api/queues/%2F/audit",
+		"/api/policies/%2F/audit-ttl",
+	} {
+		if !api.has(path) {
+			t.Errorf("%s was not declared", path)
+		}
+	}
+	for _, path := range []string{"/api/bindings/%2F/e/orders/q/invoices", "/api/bindings/%2F/e/orders/q/audit"} {
+		if count := api.bindingCount(path); count != 1 {
+			t.Errorf("expected 1 binding at %s, got %d", path, count)
+		}
+	}
+	if _, err := os.Stat(options.ModelPath(rabbitMqManagementStateFile)); err != nil {
+		t.Errorf("state file was not written: %v", err)
+	}
+}
+
+func TestExecuteRetriesUnavailableAPI(t *testing.T) {
+	api := newFakeManagementAPI()
+	api.unavailable = 2
+	server := httptest.NewServer(api)
+	defer server.Close()
+	options := writeTestDefinitions(t, t.TempDir(), testDefinitions())
+
+	if err := newTestPlugin(server).Execute(options); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if !api.has("/api/exchanges/%2F/orders") {
+		t.Error("exchange was not declared after the API became available")
+	}
+	if api.requests[0] != api.requests[2] {
+		t.Errorf("expected the first request to be retried, got %v", api.requests[:3])
+	}
+}
+
+func TestExecuteGivesUpAfterRetries(t *testing.T) {
+	api := newFakeManagementAPI()
+	api.unavailable = 100
+	server := httptest.NewServer(api)
+	defer server.Close()
+	options := writeTestDefinitions(t, t.TempDir(), testDefinitions())
+
+	err := newTestPlugin(server).Execute(options)
+	if err == nil || !strings.Contains(err.Error(), "failed after 4 attempts") {
+		t.Fatalf("expected the request to fail after 4 attempts, got %v", err)
+	}
+	if len(api.requests) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(api.requests))
+	}
+}
+
+func TestExecuteRejectsChangedQueue(t *testing.T) {
+	api := newFakeManagementAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+	plugin := newTestPlugin(server)
+	dir := t.TempDir()
+
+	if err := plugin.Execute(writeTestDefinitions(t, dir, testDefinitions())); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	changed := testDefinitions()
+	changed.Queues[0].Durable = false
+	err := plugin.Execute(writeTestDefinitions(t, dir, changed))
+	if err == nil || !strings.Contains(err.Error(), "queue invoices exists with different settings") {
+		t.Fatalf("expected the changed queue to be rejected, got %v", err)
+	}
+}
+
+func TestExecuteDeletesRemovedResources(t *testing.T) {
+	api := newFakeManagementAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+	plugin := newTestPlugin(server)
+	dir := t.TempDir()
+
+	if err := plugin.Execute(writeTestDefinitions(t, dir, testDefinitions())); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	//the audit queue, its binding and its policy are removed from the model
+	reduced := testDefinitions()
+	reduced.Queues = reduced.Queues[:1]
+	reduced.Bindings = reduced.Bindings[:1]
+	reduced.Policies = nil
+	if err := plugin.Execute(writeTestDefinitions(t, dir, reduced)); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if api.has("/api/queues/%2F/audit") {
+		t.Error("removed queue was not deleted")
+	}
+	if api.has("/api/policies/%2F/audit-ttl") {
+		t.Error("removed policy was not deleted")
+	}
+	if count := api.bindingCount("/api/bindings/%2F/e/orders/q/audit"); count != 0 {
+		t.Errorf("removed binding was not deleted, %d left", count)
+	}
+	if !api.has("/api/queues/%2F/invoices") || api.bindingCount("/api/bindings/%2F/e/orders/q/invoices") != 1 {
+		t.Error("resources that are still part of the model were deleted")
+	}
+}
+
+func TestDestroyIgnoresMissingResources(t *testing.T) {
+	api := newFakeManagementAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+	plugin := newTestPlugin(server)
+	options := writeTestDefinitions(t, t.TempDir(), testDefinitions())
+
+	if err := plugin.Execute(options); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	//resources deleted by someone else answer the DELETE with 404
+	api.mu.Lock()
+	delete(api.resources, "/api/queues/%2F/audit")
+	delete(api.resources, "/api/policies/%2F/audit-ttl")
+	api.mu.Unlock()
+
+	if err := plugin.Destroy(options); err != nil {
+		t.Fatalf("destroy failed: %v", err)
+	}
+	if api.has("/api/exchanges/%2F/orders") || api.has("/api/queues/%2F/invoices") {
+		t.Error("destroy left resources of the deployment")
+	}
+	if _, err := os.Stat(options.ModelPath(rabbitMqManagementStateFile)); !os.IsNotExist(err) {
+		t.Errorf("state file was not removed: %v", err)
+	}
+}
+
+func TestExecuteCreatesVhosts(t *testing.T) {
+	api := newFakeManagementAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+	plugin := newTestPlugin(server)
+	dir := t.TempDir()
+
+	//the queue lives in a vhost of its own, the exchange and binding in the vhost of the host
+	definitions := transformators.RabbitMqDefinitions{
+		Vhosts: []transformators.RabbitMqVhostDefinition{{Name: "shop"}, {Name: "billing"}},
+		Exchanges: []transformators.RabbitMqExchangeDefinition{
+			{Name: "orders", Vhost: "shop", Type: "topic", Durable: true},
+		},
+		Queues: []transformators.RabbitMqQueueDefinition{
+			{Name: "invoices", Vhost: "billing", Durable: true},
+			{Name: "shipping", Vhost: "shop", Durable: true},
+		},
+		Bindings: []transformators.RabbitMqBindingDefinition{
+			{Source: "orders", Vhost: "shop", Destination: "shipping", DestinationType: "queue", RoutingKey: "#"},
+		},
+	}
+	if err := plugin.Execute(writeTestDefinitions(t, dir, definitions)); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	for _, path := range []string{
+		"/api/vhosts/shop",
+		"/api/vhosts/billing",
+		"/api/permissions/shop/guest",
+		"/api/permissions/billing/guest",
+		"/api/exchanges/shop/orders",
+		"/api/queues/billing/invoices",
+		"/api/queues/shop/shipping",
+	} {
+		if !api.has(path) {
+			t.Errorf("%s was not declared", path)
+		}
+	}
+	if count := api.bindingCount("/api/bindings/shop/e/orders/q/shipping"); count != 1 {
+		t.Errorf("expected 1 binding in vhost shop, got %d", count)
+	}
+
+	//a vhost removed from the model is deleted with its resources
+	definitions.Vhosts = definitions.Vhosts[:1]
+	definitions.Queues = definitions.Queues[1:]
+	options := writeTestDefinitions(t, dir, definitions)
+	if err := plugin.Execute(options); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if api.has("/api/vhosts/billing") || api.has("/api/queues/billing/invoices") {
+		t.Error("removed vhost was not deleted")
+	}
+
+	if err := plugin.Destroy(options); err != nil {
+		t.Fatalf("destroy failed: %v", err)
+	}
+	if api.has("/api/vhosts/shop") {
+		t.Error("destroy left the vhost of the deployment")
+	}
+}
@@ -10,20 +10,26 @@ import (
 
 type TerraformPlugin struct{}
 
-func (p *TerraformPlugin) Execute() error {
-	terraformModelPath := filepath.Join(".", "rabbitMqModel.tf")
+//terraform apply is incremental by itself, unchanged queues and their messages are kept
+func (p *TerraformPlugin) Execute(options ExecutionOptions) error {
+	terraformModelPath := options.ModelPath("rabbitMqModel.tf")
 
 	if _, err := os.Stat(terraformModelPath); os.IsNotExist(err) {
 		return fmt.Errorf("rabbitMqModel.tf file not found: %w", err)
 	}
 
 	initCmd := exec.Command("terraform", "init")
+	initCmd.Dir = options.WorkDir
+	initCmd.Env = options.SecretEnv()
 	initOutput, err := initCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to initialize Terraform: %w, output: %s", err, string(initOutput))
 	}
 
-	applyCmd := exec.Command("terraform", "apply", "-auto-approve")
+	//the provider credentials are sensitive variables set through the environment, terraform must not prompt for them
+	applyCmd := exec.Command("terraform", "apply", "-auto-approve", "-input=false")
+	applyCmd.Dir = options.WorkDir
+	applyCmd.Env = options.SecretEnv()
 	applyCmd.Stdout = os.Stdout
 	applyCmd.Stderr = os.Stderr
 
@@ -42,8 +48,8 @@ func (p *TerraformPlugin) Execute() error {
 	return nil
 }
 
-func (p *TerraformPlugin) Destroy() error {
-	terraformModelPath := filepath.Join(".", "rabbitMqModel.tf")
+func (p *TerraformPlugin) Destroy(options ExecutionOptions) error {
+	terraformModelPath := options.ModelPath("rabbitMqModel.tf")
 
 	if _, err := os.Stat(terraformModelPath); os.IsNotExist(err) {
 		fmt.Println("rabbitMqModel.tf file not found. Skipping destruction process.")
@@ -51,12 +57,16 @@ func (p *TerraformPlugin) Destroy() error {
 	}
 
 	initCmd := exec.Command("terraform", "init")
+	initCmd.Dir = options.WorkDir
+	initCmd.Env = options.SecretEnv()
 	initOutput, err := initCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to initialize Terraform: %w, output: %s", err, string(initOutput))
 	}
 
-	destroyCmd := exec.Command("terraform", "destroy", "-auto-approve")
+	destroyCmd := exec.Command("terraform", "destroy", "-auto-approve", "-input=false")
+	destroyCmd.Dir = options.WorkDir
+	destroyCmd.Env = options.SecretEnv()
 	destroyOutput, err := destroyCmd.CombinedOutput()
 	if err != nil {
 		if strings.Contains(string(destroyOutput), "No changes. Infrastructure is up-to-date.") || strings.Contains(string(destroyOutput), "No resources found") {
@@ -71,6 +81,7 @@ func (p *TerraformPlugin) Destroy() error {
 	fmt.Println("Cleaning up Terraform state and lock files...")
 	stateFiles := []string{"terraform.tfstate", "terraform.tfstate.backup", "terraform.lock.hcl"}
 	for _, stateFile := range stateFiles {
+		stateFile = filepath.Join(options.WorkDir, stateFile)
 		if _, err := os.Stat(stateFile); err == nil {
 			err := os.Remove(stateFile)
 			if err != nil {
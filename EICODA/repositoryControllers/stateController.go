@@ -0,0 +1,180 @@
+package repositoryControllers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"eicoda/models"
+)
+
+//directory holding the working directories and records of all deployments
+var deploymentsDir = filepath.Join(".eicoda", "deployments")
+
+// handles the persisted records of deploy and destroy runs of one named deployment
+type StateController struct {
+	name       string
+	workDir    string
+	historyDir string
+}
+
+func NewStateController(deploymentName string) *StateController {
+	workDir := filepath.Join(deploymentsDir, deploymentName)
+	return &StateController{
+		name:       deploymentName,
+		workDir:    workDir,
+		historyDir: filepath.Join(workDir, "history"),
+	}
+}
+
+//returns the directory the generated models and terraform state of the deployment live in
+func (sc *StateController) WorkDir() string {
+	return sc.workDir
+}
+
+//returns the key the credentials of the deployment are hashed with, it is created on first use and only readable by
+//the owner, so the recorded hash cannot be used to guess the credentials
+func (sc *StateController) SecretsKey() ([]byte, error) {
+	keyPath := filepath.Join(sc.workDir, "secrets.key")
+	key, err := ioutil.ReadFile(keyPath)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read secrets key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to create secrets key: %w", err)
+	}
+	err = ioutil.WriteFile(keyPath, key, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write secrets key: %w", err)
+	}
+	return key, nil
+}
+
+//returns the names of all deployments that have a working directory
+func ListDeployments() ([]string, error) {
+	entries, err := ioutil.ReadDir(deploymentsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read deployments directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//creates a new record with a unique, chronologically sortable ID
+func (sc *StateController) NewRecord(action string) *models.DeploymentRecord {
+	now := time.Now()
+	return &models.DeploymentRecord{
+		ID:        now.Format("20060102-150405.000000"),
+		Name:      sc.name,
+		Action:    action,
+		Timestamp: now.Format(time.RFC3339),
+	}
+}
+
+//persists a record as its own file in the history directory
+func (sc *StateController) SaveRecord(record *models.DeploymentRecord) error {
+	err := os.MkdirAll(sc.historyDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deployment record: %w", err)
+	}
+
+	recordPath := filepath.Join(sc.historyDir, record.ID+".yaml")
+	err = ioutil.WriteFile(recordPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write deployment record: %w", err)
+	}
+
+	return nil
+}
+
+//returns all records, oldest first
+func (sc *StateController) History() ([]models.DeploymentRecord, error) {
+	entries, err := ioutil.ReadDir(sc.historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".yaml") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var records []models.DeploymentRecord
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(sc.historyDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read deployment record %s: %w", name, err)
+		}
+		var record models.DeploymentRecord
+		err = yaml.Unmarshal(data, &record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse deployment record %s: %w", name, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+//returns the most recent record or nil if nothing was recorded yet
+func (sc *StateController) Latest() (*models.DeploymentRecord, error) {
+	records, err := sc.History()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[len(records)-1], nil
+}
+
+//returns the record of the deployment that is currently applied or nil if nothing is deployed
+func (sc *StateController) LastApplied() (*models.DeploymentRecord, error) {
+	records, err := sc.History()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Action == "destroy" && record.Status == models.StatusDestroyed {
+			return nil, nil
+		}
+		if record.Action == "deploy" && record.Status == models.StatusSucceeded {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
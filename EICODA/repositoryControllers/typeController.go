@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 	"eicoda/models"
+	"eicoda/transformators"
 )
 
 //represents the structure of the combined types and artifacts YAML file
@@ -78,6 +80,10 @@ func (tc *TypeController) AddType(path string) error {
 	}
 
 	for _, artifact := range newCombinedTypes.DeploymentArtifacts {
+		if err := validateDeploymentArtifact(artifact); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+
 		for _, existingArtifact := range tc.deploymentArtifacts {
 			if existingArtifact.Name == artifact.Name {
 				return fmt.Errorf("duplicate deployment artifact name found: %s", artifact.Name)
@@ -89,7 +95,12 @@ func (tc *TypeController) AddType(path string) error {
 
 	tc.mergeHosts(newCombinedTypes.Hosts)
 
-	for _, filterType := range tc.filterTypes {
+	resolvedTypes, err := models.ResolveFilterTypes(tc.filterTypes)
+	if err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	for _, filterType := range resolvedTypes {
 		if filterType.Artifact != "" {
 			if !tc.isValidArtifact(filterType.Artifact) {
 				return fmt.Errorf("invalid artifact specified: %s", filterType.Artifact)
@@ -121,7 +132,7 @@ func (tc *TypeController) AddType(path string) error {
 func (tc *TypeController) mergeHosts(newHosts models.Hosts) {
 	for _, newHost := range newHosts.PipeHosts {
 		if !tc.hostExists(newHost, tc.hosts.PipeHosts) {
-			tc.hosts.PipeHosts = append(tc.hosts.PipeHosts, newHost)
+			tc.hosts.PipeHosts = append(tc.hosts.PipeHosts, referencePlaintextCredentials(newHost))
 		}
 	}
 
@@ -132,6 +143,22 @@ func (tc *TypeController) mergeHosts(newHosts models.Hosts) {
 	}
 }
 
+//persisted hosts end up in mergedTypes.yaml, so plaintext credentials are not stored but replaced by a reference to
+//the environment variable the credential is passed with at deployment
+func referencePlaintextCredentials(host models.Host) models.Host {
+	props := make(map[string]string, len(host.AdditionalProps))
+	for key, value := range host.AdditionalProps {
+		if models.IsCredentialProp(key) && value != "" && !strings.HasPrefix(value, models.SecretPrefix) {
+			envName := transformators.SecretEnvName(&host, key)
+			value = models.SecretPrefix + models.SecretSourceEnv + ":" + envName
+			fmt.Printf("Warning: the %s of host %s is not stored in plaintext, set %s when deploying.\n", key, host.Name, envName)
+		}
+		props[key] = value
+	}
+	host.AdditionalProps = props
+	return host
+}
+
 // checks if a host already exists in the given slice of hosts
 func (tc *TypeController) hostExists(newHost models.Host, existingHosts []models.Host) bool {
 	for _, host := range existingHosts {
@@ -150,8 +177,8 @@ func validateFilterType(ft models.FilterType) error {
 
 	if ft.Configs != nil {
 		for _, config := range ft.Configs {
-			if config.Name == "" {
-				return fmt.Errorf("config name cannot be empty")
+			if err := config.Check(); err != nil {
+				return fmt.Errorf("filter type %s: %w", ft.Name, err)
 			}
 		}
 	}
@@ -159,6 +186,26 @@ func validateFilterType(ft models.FilterType) error {
 	return nil
 }
 
+//validates the name and internal pipes of a deployment artifact
+func validateDeploymentArtifact(da models.DeploymentArtifact) error {
+	if da.Name == "" {
+		return fmt.Errorf("deployment artifact name is required")
+	}
+
+	internalPipeNames := make(map[string]bool)
+	for _, internalPipe := range da.InternalPipes {
+		if err := internalPipe.Validate(); err != nil {
+			return fmt.Errorf("deployment artifact %s: %w", da.Name, err)
+		}
+		if internalPipeNames[internalPipe.Name] {
+			return fmt.Errorf("deployment artifact %s: duplicate internal pipe %s", da.Name, internalPipe.Name)
+		}
+		internalPipeNames[internalPipe.Name] = true
+	}
+
+	return nil
+}
+
 //checks if the artifact is valid
 func (tc *TypeController) isValidArtifact(artifact string) bool {
 	for _, da := range tc.deploymentArtifacts {
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"eicoda/models"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+//properties every filter has regardless of its type
+var baseFilterProperties = map[string]interface{}{
+	"id":       map[string]interface{}{"type": "string"},
+	"name":     map[string]interface{}{"type": "string"},
+	"host":     map[string]interface{}{"type": "string", "description": "Name of a filterHost"},
+	"type":     map[string]interface{}{"type": "string"},
+	"artifact": map[string]interface{}{"type": "string", "description": "Deployment artifact, overrides the artifact of the filter type"},
+	"mappings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/mapping"}},
+	"replicas": map[string]interface{}{"type": "integer", "minimum": 1, "description": "Number of instances of the filter, defaults to 1"},
+	"enabled":  map[string]interface{}{"type": "boolean", "description": "Disabled filters are not deployed unless a profile enables them"},
+}
+
+//properties every host has regardless of its type
+var baseHostProperties = map[string]interface{}{
+	"id":   map[string]interface{}{"type": "string"},
+	"name": map[string]interface{}{"type": "string"},
+	"type": map[string]interface{}{"type": "string"},
+}
+
+//generates a JSON Schema for deployment models that allows the filter and host types of the type repository,
+//the filter types have to be resolved already
+func GenerateSchema(combinedTypes *models.CombinedTypes, hostTypes models.HostTypes) ([]byte, error) {
+	pipe := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":       map[string]interface{}{"type": "string"},
+			"name":     map[string]interface{}{"type": "string"},
+			"host":     map[string]interface{}{"type": "string", "description": "Name of a pipeHost"},
+			"protocol": map[string]interface{}{"enum": pipeProtocols},
+			"configs":  queueConfigsSchema(),
+			"exchangeType": map[string]interface{}{
+				"enum":        models.ExchangeTypes,
+				"description": "Type of the exchange of an amqp topic of a RabbitMQ host, defaults to topic",
+			},
+			"partitions": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     1,
+				"description": "Partitions of the kafka topic, only for pipes of Kafka hosts",
+			},
+			"replicationFactor": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     1,
+				"description": "Replication factor of the kafka topic, only for pipes of Kafka hosts",
+			},
+			"retention": map[string]interface{}{
+				"type":        []string{"string", "integer"},
+				"description": "Retention of the kafka topic, e.g. 7d, 12h, milliseconds or -1, only for pipes of Kafka hosts",
+			},
+		},
+		"required": []string{"name", "host", "protocol"},
+	}
+
+	schema := map[string]interface{}{
+		"$schema":     jsonSchemaDraft,
+		"title":       "EICODA deployment model",
+		"type":        "object",
+		"definitions": map[string]interface{}{
+			"mapping":    mappingSchema(),
+			"filter":     filterSchema(combinedTypes.FilterTypes),
+			"pipeHost":   hostSchema(hostTypes.PipeHosts),
+			"filterHost": hostSchema(hostTypes.FilterHosts),
+			"pipe":       pipe,
+			"binding":    bindingSchema(),
+		},
+		"properties": map[string]interface{}{
+			"imports": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Model files relative to this model, globs are allowed",
+			},
+			"variables": map[string]interface{}{
+				"type":        "object",
+				"description": "Variables with their default values, referenced as ${name}",
+			},
+			"generators": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"forEach": map[string]interface{}{"type": []string{"array", "string"}},
+						"range": map[string]interface{}{
+							"type":       "object",
+							"properties": map[string]interface{}{"from": map[string]interface{}{}, "to": map[string]interface{}{}},
+							"required":   []string{"from", "to"},
+						},
+						"as":       map[string]interface{}{"type": "string"},
+						"filters":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+						"queues":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+						"topics":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+						"bindings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+					},
+				},
+			},
+			"profiles": map[string]interface{}{
+				"type":        "object",
+				"description": "Patches of hosts, filters and pipes per environment, selected with --profile",
+				"additionalProperties": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"hosts":   map[string]interface{}{"type": "object"},
+						"filters": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object", "required": []string{"name"}}},
+						"pipes":   map[string]interface{}{"type": "object"},
+					},
+					"additionalProperties": false,
+				},
+			},
+			"pipes": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"queues":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/pipe"}},
+					"topics":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/pipe"}},
+					"bindings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/binding"}},
+				},
+			},
+			"filters": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/filter"}},
+			"hosts": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pipeHosts":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/pipeHost"}},
+					"filterHosts": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/filterHost"}},
+				},
+			},
+			"filterTypes":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+			"deploymentArtifacts": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+		},
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+//binds a queue or topic to the exchange of a topic
+func bindingSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"source":      map[string]interface{}{"type": "string", "description": "Name of an amqp topic of a RabbitMQ host"},
+			"destination": map[string]interface{}{"type": "string", "description": "Name of a queue or topic of the same host"},
+			"routingKey":  map[string]interface{}{"type": "string", "description": "Routing key pattern, only for topic and direct exchanges"},
+			"arguments": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "Header values to match, only for headers exchanges. x-match selects all or any",
+			},
+		},
+		"required":             []string{"source", "destination"},
+		"additionalProperties": false,
+	}
+}
+
+//accepts the legacy string form and the structured form of a mapping
+func mappingSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{
+				"type":        "string",
+				"pattern":     "^[^:]+:.+$",
+				"description": "internal:pipe->routingKey",
+			},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"port":       map[string]interface{}{"type": "string"},
+					"pipe":       map[string]interface{}{"type": "string"},
+					"routingKey": map[string]interface{}{"type": "string"},
+					"prefetch":   map[string]interface{}{"type": "integer", "minimum": 0},
+					"arguments":  map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+				},
+				"required":             []string{"port", "pipe"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+//returns the schema of a filter, each known type adds its config properties and forbids all others.
+//types not in the repository (e.g. defined in the model itself) and Custom filters are not restricted
+func filterSchema(filterTypes []models.FilterType) map[string]interface{} {
+	var typeNames []string
+	var conditions []interface{}
+	seen := make(map[string]bool)
+	for _, filterType := range filterTypes {
+		if filterType.Name == "" || filterType.Abstract || seen[filterType.Name] {
+			continue
+		}
+		seen[filterType.Name] = true
+		typeNames = append(typeNames, filterType.Name)
+		if filterType.Name == "Custom" {
+			continue
+		}
+
+		properties := copyProperties(baseFilterProperties)
+		var required []string
+		for _, config := range filterType.Configs {
+			properties[config.Name] = configSchema(config)
+			if config.Default == nil {
+				required = append(required, config.Name)
+			}
+		}
+		if filterType.Stateful {
+			properties["replicas"] = map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1, "description": "Stateful filters cannot be scaled"}
+		}
+
+		then := map[string]interface{}{
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			then["required"] = required
+		}
+		conditions = append(conditions, typeCondition(filterType.Name, then))
+	}
+
+	properties := copyProperties(baseFilterProperties)
+	properties["type"] = map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"enum": typeNames},
+			map[string]interface{}{"type": "string"},
+		},
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"name", "host", "type"},
+	}
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+	return schema
+}
+
+//returns the schema of a single config property of a filter type
+func configSchema(config models.FilterConfig) map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch {
+	case config.IsFile():
+		schema["type"] = "string"
+		if config.Description == "" {
+			config.Description = "Path to a file relative to the deployment model"
+		}
+	case config.Type == models.ConfigTypeInt:
+		schema["type"] = "integer"
+	case config.Type == models.ConfigTypeFloat:
+		schema["type"] = "number"
+	case config.Type == models.ConfigTypeBool:
+		schema["type"] = "boolean"
+	case config.Type == models.ConfigTypeEnum:
+		schema["enum"] = config.Values
+	case config.Type == models.ConfigTypeDuration:
+		schema["type"] = []string{"string", "integer"}
+	case config.Type == "":
+		schema["type"] = []string{"string", "number", "boolean"}
+	default:
+		schema["type"] = "string"
+	}
+
+	//durations can be given as text, so their bounds can only be enforced by the parser
+	if config.Type != models.ConfigTypeDuration {
+		if config.Min != nil {
+			schema["minimum"] = *config.Min
+		}
+		if config.Max != nil {
+			schema["maximum"] = *config.Max
+		}
+	}
+	if config.Description != "" {
+		schema["description"] = config.Description
+	}
+
+	switch config.Default.(type) {
+	case nil:
+	case string, int, float64, bool:
+		schema["default"] = config.Default
+	default:
+		schema["default"] = fmt.Sprintf("%v", config.Default)
+	}
+	return schema
+}
+
+//returns the schema of the configs of a queue on a RabbitMQ host
+func queueConfigsSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "object",
+		"description": "Settings of a queue on a RabbitMQ host",
+		"properties": map[string]interface{}{
+			"vhost":       map[string]interface{}{"type": "string"},
+			"type":        map[string]interface{}{"enum": []string{models.QueueTypeClassic, models.QueueTypeQuorum, models.QueueTypeStream}},
+			"durable":     map[string]interface{}{"type": "boolean"},
+			"autoDelete":  map[string]interface{}{"type": "boolean"},
+			"exclusive":   map[string]interface{}{"type": "boolean"},
+			"maxPriority": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 255},
+			"messageTTL":  map[string]interface{}{"type": []string{"string", "integer"}, "description": "e.g. 30s, 1h or milliseconds"},
+			"maxLength":   map[string]interface{}{"type": "integer", "minimum": 1},
+			"overflow":    map[string]interface{}{"enum": models.QueueOverflowModes},
+			"lazy":        map[string]interface{}{"type": "boolean"},
+			"applyAs":     map[string]interface{}{"enum": []string{models.ApplyAsPolicy, models.ApplyAsArguments}},
+		},
+		"additionalProperties": false,
+	}
+}
+
+//returns the schema of a host, each host type requires its configs, allows its optional configs and forbids all others
+func hostSchema(hostTypes []models.HostType) map[string]interface{} {
+	var typeNames []string
+	var conditions []interface{}
+	for _, hostType := range hostTypes {
+		typeNames = append(typeNames, hostType.Name)
+
+		properties := copyProperties(baseHostProperties)
+		for _, config := range append(append([]string{}, hostType.Configs...), hostType.OptionalConfigs...) {
+			properties[config] = map[string]interface{}{"type": []string{"string", "number"}}
+		}
+
+		then := map[string]interface{}{
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(hostType.Configs) > 0 {
+			then["required"] = hostType.Configs
+		}
+		conditions = append(conditions, typeCondition(hostType.Name, then))
+	}
+
+	properties := copyProperties(baseHostProperties)
+	properties["type"] = map[string]interface{}{"enum": typeNames}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"name", "type"},
+	}
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+	return schema
+}
+
+//applies the given schema if the type property equals the type name
+func typeCondition(typeName string, then map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"if": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"type": map[string]interface{}{"const": typeName},
+			},
+			"required": []string{"type"},
+		},
+		"then": then,
+	}
+}
+
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"eicoda/models"
+)
+
+//represents a mapping of an internal pipe of a filter to a pipe of the model.
+//declared is set if the direction comes from the deployment artifact instead of being derived from the name
+type PipeConnection struct {
+	Filter     string
+	FilterType string
+	Port       string
+	Pipe       string
+	RoutingKey string
+	Direction  string
+	Declared   bool
+}
+
+//represents the pipes-and-filters graph of a model
+type Topology struct {
+	Filters     []models.Filter
+	PipeKinds   map[string]string
+	Connections []PipeConnection
+	Bindings    []models.Binding
+}
+
+//holds the findings of the topology analysis, errors make a model invalid while warnings are only reported
+type TopologyReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+//builds the graph of filters and pipes from the mappings of a model
+func BuildTopology(model *models.Model) *Topology {
+	topology := &Topology{
+		Filters:   model.Filters,
+		PipeKinds: make(map[string]string),
+		Bindings:  model.Pipes.Bindings,
+	}
+	for _, queue := range model.Pipes.Queues {
+		topology.PipeKinds[queue.Name] = "queue"
+	}
+	for _, topic := range model.Pipes.Topics {
+		topology.PipeKinds[topic.Name] = "topic"
+	}
+
+	artifactMap := make(map[string]models.DeploymentArtifact)
+	for _, artifact := range model.DeploymentArtifacts {
+		artifactMap[artifact.Name] = artifact
+	}
+
+	for _, filter := range model.Filters {
+		artifact := artifactMap[filter.Artifact]
+		for _, mapping := range filter.Mappings {
+			connection := PipeConnection{
+				Filter:     filter.Name,
+				FilterType: filter.Type,
+				Port:       mapping.Port,
+				Pipe:       mapping.Pipe,
+				RoutingKey: mapping.RoutingKey,
+			}
+			connection.Direction, connection.Declared = artifact.PortDirection(mapping.Port)
+			topology.Connections = append(topology.Connections, connection)
+		}
+	}
+
+	return topology
+}
+
+func isProducer(connection PipeConnection) bool {
+	return connection.Direction == models.DirectionOutput || connection.Direction == models.DirectionBoth
+}
+
+func isConsumer(connection PipeConnection) bool {
+	return connection.Direction == models.DirectionInput || connection.Direction == models.DirectionBoth
+}
+
+//analyzes the graph for dangling pipes, unreachable filters, cycles and competing consumers
+func AnalyzeTopology(model *models.Model) TopologyReport {
+	topology := BuildTopology(model)
+	var report TopologyReport
+
+	analyzePipes(topology, &report)
+	analyzeReachability(topology, &report)
+	analyzeCycles(topology, &report)
+
+	return report
+}
+
+//reports pipes that are not used, have no producer or no consumer and queues with competing consumers of different types
+func analyzePipes(topology *Topology, report *TopologyReport) {
+	var pipeNames []string
+	for name := range topology.PipeKinds {
+		pipeNames = append(pipeNames, name)
+	}
+	sort.Strings(pipeNames)
+
+	for _, pipe := range pipeNames {
+		kind := topology.PipeKinds[pipe]
+		producers := 0
+		consumers := 0
+		consumerTypes := make(map[string]bool)
+		var declaredInputs []PipeConnection
+		//messages reach a pipe from the pipes bound to it and leave it to the pipes it is bound to
+		upstream := topology.boundPipes(pipe, false)
+		downstream := topology.boundPipes(pipe, true)
+		for _, connection := range topology.Connections {
+			if upstream[connection.Pipe] && isProducer(connection) {
+				producers++
+			}
+			if downstream[connection.Pipe] && isConsumer(connection) {
+				consumers++
+				if connection.Pipe == pipe {
+					consumerTypes[connection.FilterType] = true
+				}
+			}
+			if connection.Pipe == pipe && connection.Declared && connection.Direction == models.DirectionInput {
+				declaredInputs = append(declaredInputs, connection)
+			}
+		}
+
+		switch {
+		case producers == 0 && consumers == 0:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s %s is not mapped by any filter", kind, pipe))
+		case producers == 0 && len(declaredInputs) > 0:
+			//filters whose artifacts declare an input would wait forever
+			for _, connection := range declaredInputs {
+				report.Errors = append(report.Errors, fmt.Sprintf("filter %s reads %s %s through input %s, but no filter publishes to it", connection.Filter, kind, pipe, connection.Port))
+			}
+		case producers == 0:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s %s has no producer in the model", kind, pipe))
+		case consumers == 0:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s %s has no consumer in the model, messages will not be processed", kind, pipe))
+		}
+
+		if kind == "queue" && len(consumerTypes) > 1 {
+			var types []string
+			for filterType := range consumerTypes {
+				types = append(types, filterType)
+			}
+			sort.Strings(types)
+			report.Warnings = append(report.Warnings, fmt.Sprintf("queue %s is consumed by filters of different types (%s), they compete for its messages", pipe, strings.Join(types, ", ")))
+		}
+	}
+}
+
+//reports filters that cannot receive messages from any source, sources are filters without inputs
+func analyzeReachability(topology *Topology, report *TopologyReport) {
+	hasInput := make(map[string]bool)
+	for _, connection := range topology.Connections {
+		if connection.Direction == models.DirectionInput {
+			hasInput[connection.Filter] = true
+		}
+	}
+
+	reached := make(map[string]bool)
+	var queue []string
+	for _, filter := range topology.Filters {
+		if !hasInput[filter.Name] {
+			reached[filter.Name] = true
+			queue = append(queue, filter.Name)
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range topology.successors(current, false) {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for _, filter := range topology.Filters {
+		if !reached[filter.Name] {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("filter %s is not reachable from any source filter", filter.Name))
+		}
+	}
+}
+
+//reports cycles of filters connected through pipes, only internal pipes with a known direction are considered
+func analyzeCycles(topology *Topology, report *TopologyReport) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	reported := make(map[string]bool)
+
+	var visit func(filter string)
+	visit = func(filter string) {
+		state[filter] = visiting
+		path = append(path, filter)
+		for _, next := range topology.successors(filter, true) {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				start := 0
+				for i, name := range path {
+					if name == next {
+						start = i
+						break
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), next)
+				key := canonicalCycle(path[start:])
+				if !reported[key] {
+					reported[key] = true
+					report.Errors = append(report.Errors, fmt.Sprintf("cycle between filters: %s", strings.Join(cycle, " -> ")))
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[filter] = done
+	}
+
+	for _, filter := range topology.Filters {
+		if state[filter.Name] == unvisited {
+			visit(filter.Name)
+		}
+	}
+}
+
+//returns the filters that consume messages the given filter produces, strict only follows internal pipes with a known direction
+func (topology *Topology) successors(filter string, strict bool) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, out := range topology.Connections {
+		if out.Filter != filter || !isProducer(out) || (strict && out.Direction == models.DirectionBoth) {
+			continue
+		}
+		targets := topology.boundPipes(out.Pipe, true)
+		for _, in := range topology.Connections {
+			if !targets[in.Pipe] || !isConsumer(in) || (strict && in.Direction == models.DirectionBoth) {
+				continue
+			}
+			if in.Filter == filter && in.Port == out.Port {
+				continue
+			}
+			if !seen[in.Filter] {
+				seen[in.Filter] = true
+				result = append(result, in.Filter)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+//returns the pipe and all pipes connected to it through bindings, downstream follows the bindings from source to
+//destination and upstream in reverse
+func (topology *Topology) boundPipes(pipe string, downstream bool) map[string]bool {
+	pipes := map[string]bool{pipe: true}
+	pending := []string{pipe}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		for _, binding := range topology.Bindings {
+			from, to := binding.Source, binding.Destination
+			if !downstream {
+				from, to = to, from
+			}
+			if from == current && !pipes[to] {
+				pipes[to] = true
+				pending = append(pending, to)
+			}
+		}
+	}
+	return pipes
+}
+
+//returns the names of the pipes the given filter publishes to
+func (topology *Topology) outputPipes(filter string) map[string]bool {
+	pipes := make(map[string]bool)
+	for _, connection := range topology.Connections {
+		if connection.Filter == filter && isProducer(connection) {
+			pipes[connection.Pipe] = true
+		}
+	}
+	return pipes
+}
+
+//returns an order independent key of a cycle so that it is only reported once
+func canonicalCycle(filters []string) string {
+	sorted := append([]string{}, filters...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
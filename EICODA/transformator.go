@@ -2,26 +2,27 @@ package main
 
 import (
 	"eicoda/models"
+	"eicoda/transformators"
 )
 
 type Transformator interface {
-	Transform(model *models.Model, writeFile bool, baseDir string) (string, error)
+	Transform(model *models.Model, options transformators.Options) (string, error)
 }
 
 type DockerComposeTransformator struct{}
 
-func (t *DockerComposeTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+func (t *DockerComposeTransformator) Transform(model *models.Model, options transformators.Options) (string, error) {
 	return "DockerCompose transformed model", nil
 }
 
 type KubernetesTransformator struct{}
 
-func (t *KubernetesTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+func (t *KubernetesTransformator) Transform(model *models.Model, options transformators.Options) (string, error) {
 	return "Kubernetes transformed model", nil
 }
 
 type RabbitMqTransformator struct{}
 
-func (t *RabbitMqTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+func (t *RabbitMqTransformator) Transform(model *models.Model, options transformators.Options) (string, error) {
 	return "RabbitMQ transformed model", nil
 }
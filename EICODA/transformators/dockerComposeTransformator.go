@@ -11,18 +11,24 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+//label and annotation holding the hash of the file backed configs of a filter
+const ConfigHashLabel = "eicoda.config-hash"
+
+//file name of the generated Docker Compose model
+const DockerComposeModelFile = "docker-compose.yaml"
+
 type DockerComposeTransformator struct{}
 
 //transforms the model to Docker Compose format and optionally writes to a file
-func (t *DockerComposeTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+func (t *DockerComposeTransformator) Transform(model *models.Model, options Options) (string, error) {
 	services := make(map[string]interface{})
 	volumes := map[string]interface{}{}
 
-	for _, filter := range model.Filters {
+	for _, filter := range utils.SortFiltersByName(model.Filters) {
 		host := utils.FindHostByName(model.Hosts.FilterHosts, filter.Host)
 		if host != nil && host.Type == "DockerEngine" {
 			image := utils.FindArtifactImage(model.DeploymentArtifacts, filter.Artifact)
-			service, serviceVolumes := createDockerComposeService(model, filter, image, baseDir)
+			service, serviceVolumes := createDockerComposeService(model, filter, image, options)
 			serviceName := utils.SanitizeName(filter.Name)
 			services[serviceName] = service
 			for _, volume := range serviceVolumes {
@@ -46,8 +52,8 @@ func (t *DockerComposeTransformator) Transform(model *models.Model, writeFile bo
 	encoder.Close()
 
 	//write to file if writeFile is true
-	if writeFile {
-		outputPath := "docker-compose.yaml"
+	if options.WriteFile {
+		outputPath := options.OutputPath(DockerComposeModelFile)
 		err := os.WriteFile(outputPath, []byte(sb.String()), 0644)
 		if err != nil {
 			return "", fmt.Errorf("failed to write Docker Compose model to file: %w", err)
@@ -57,70 +63,32 @@ func (t *DockerComposeTransformator) Transform(model *models.Model, writeFile bo
 	return sb.String(), nil
 }
 
-func createDockerComposeService(model *models.Model, filter models.Filter, image string, baseDir string) (map[string]interface{}, []string) {
+func createDockerComposeService(model *models.Model, filter models.Filter, image string, options Options) (map[string]interface{}, []string) {
 	envVars := []string{}
 	volumes := []string{}
 	volumeMounts := []string{}
 
 	for _, mapping := range filter.Mappings {
-		parts := strings.Split(mapping, ":")
-		if len(parts) == 2 {
-			pipeMapping := parts[1]
-			var pipeName, routingKey string
-
-			//check if the mapping has a routing key
-			if strings.Contains(pipeMapping, "->") {
-				pipeParts := strings.Split(pipeMapping, "->")
-				if len(pipeParts) == 2 {
-					pipeName = pipeParts[0]
-					routingKey = pipeParts[1]
-				} else {
-					pipeName = pipeMapping
-				}
-			} else {
-				pipeName = pipeMapping
-			}
-
-			var pipeType string
-			var pipeHost *models.Host
-			var pipeProtocol string
-
-			queue := utils.FindQueueByName(model.Pipes.Queues, pipeName)
-			if queue != nil {
-				pipeType = "queue"
-				pipeHost = utils.FindHostByName(model.Hosts.PipeHosts, queue.Host)
-				pipeProtocol = queue.Protocol
-			} else {
-				topic := utils.FindTopicByName(model.Pipes.Topics, pipeName)
-				if topic != nil {
-					pipeType = "topic"
-					pipeHost = utils.FindHostByName(model.Hosts.PipeHosts, topic.Host)
-					pipeProtocol = topic.Protocol
-				}
-			}
-
-			if pipeHost != nil {
-				hostAddress := pipeHost.AdditionalProps["host_address"]
-				if hostAddress == "localhost" {
-					hostAddress = "host.docker.internal"
-				}
-
-				value := fmt.Sprintf("%s://%s:%s@%s:%s,%s,%s",
-					pipeProtocol,
-					pipeHost.AdditionalProps["username"],
-					pipeHost.AdditionalProps["password"],
-					hostAddress,   //change localhost to host.docker.internal
-					pipeHost.AdditionalProps["messaging_port"],
-					pipeName,
-					pipeType,
-				)
+		pipe := resolveMappedPipe(model, filter, mapping)
+		if pipe == nil {
+			continue
+		}
 
-				envVars = append(envVars, fmt.Sprintf("%s=%s", parts[0], value))
+		//change localhost to host.docker.internal
+		hostAddress := pipe.Host.AdditionalProps["host_address"]
+		if hostAddress == "localhost" {
+			hostAddress = "host.docker.internal"
+		}
+		if pipe.Host.Type == "Kafka" {
+			hostAddress = kafkaBootstrapServers(pipe.Host, "host.docker.internal")
+		}
 
-				if routingKey != "" {
-					envVars = append(envVars, fmt.Sprintf("%sRoutingKey=%s", parts[0], routingKey))
-				}
-			}
+		//compose interpolates the credentials from the environment of docker-compose, the plugin passes them there
+		credentialRef := func(prop string) string {
+			return "${" + SecretEnvName(pipe.Host, prop) + "}"
+		}
+		for _, env := range mappingEnvVars(mapping, pipe, hostAddress, credentialRef) {
+			envVars = append(envVars, fmt.Sprintf("%s=%s", env.Name, env.Value))
 		}
 	}
 
@@ -132,30 +100,69 @@ func createDockerComposeService(model *models.Model, filter models.Filter, image
 			if !exists {
 				value = fmt.Sprintf("%v", config.Default)
 			}
-			if config.File {
-				filePath := filepath.Join(baseDir, value)
-				absoluteFilePath, err := filepath.Abs(filePath)
+			if config.IsFile() {
+				filePath := filepath.Join(options.BaseDir, value)
+				volumeName := strings.ToLower(filter.Name + "-" + config.Name)
+				mountSource, err := configFileMountSource(filePath, volumeName, options)
 				if err != nil {
-					fmt.Printf("failed to get absolute path for %s: %v\n", filePath, err)
+					fmt.Printf("failed to prepare mount for %s: %v\n", filePath, err)
 					continue
 				}
 
-				volumeName := strings.ToLower(filter.Name + "-" + config.Name)
 				volumes = append(volumes, volumeName)
-				volumeMounts = append(volumeMounts, fmt.Sprintf("%s:/etc/config/criteria", absoluteFilePath))
+				volumeMounts = append(volumeMounts, fmt.Sprintf("%s:/etc/config/criteria", mountSource))
 
 				value = "/etc/config/criteria"
 			}
 
-			envVars = append(envVars, fmt.Sprintf("%s=%s", config.Name, utils.ConvertToProperType(value)))
+			envVars = append(envVars, fmt.Sprintf("%s=%s", config.Name, utils.RenderConfigValue(config, value)))
 		}
 	}
 
+	labels := map[string]interface{}{
+		DeploymentLabel: options.DeploymentName,
+	}
+
+	//bind mounted files are invisible to docker-compose, so their hash is added as label to recreate the service when they change
+	if configHash := utils.HashFileConfigs(filterType, filter, options.BaseDir); configHash != "" {
+		labels[ConfigHashLabel] = configHash
+	}
+
 	service := map[string]interface{}{
 		"image":       image,
 		"environment": envVars,
 		"volumes":     volumeMounts,
+		"labels":      labels,
+	}
+	if filter.ReplicaCount() > 1 {
+		service["deploy"] = map[string]interface{}{
+			"replicas": filter.ReplicaCount(),
+		}
 	}
 
 	return service, volumes
 }
+
+//returns the host path a file backed config is mounted from. Portable models get a copy of the file next to
+//the generated model that is referenced relatively, otherwise the absolute path of the original file is used
+func configFileMountSource(filePath string, volumeName string, options Options) (string, error) {
+	if !options.PortableFiles {
+		return filepath.Abs(filePath)
+	}
+
+	relativePath := filepath.ToSlash(filepath.Join("config", volumeName+filepath.Ext(filePath)))
+	if options.WriteFile {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", err
+		}
+		targetPath := options.OutputPath(relativePath)
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(targetPath, content, 0644); err != nil {
+			return "", err
+		}
+	}
+	return "./" + relativePath, nil
+}
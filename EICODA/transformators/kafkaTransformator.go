@@ -0,0 +1,164 @@
+package transformators
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"eicoda/models"
+	"eicoda/utils"
+	"gopkg.in/yaml.v2"
+)
+
+//file names of the generated Strimzi topics and the script that creates the same topics with the kafka CLI
+const (
+	KafkaModelFile  = "kafkaTopics.yaml"
+	KafkaScriptFile = "kafka-topics.sh"
+)
+
+//label the Strimzi topic operator selects the cluster of a topic with
+const strimziClusterLabel = "strimzi.io/cluster"
+
+//a queue or topic of a Kafka host, both become kafka topics
+type kafkaTopic struct {
+	Name     string
+	Host     *models.Host
+	Settings models.KafkaSettings
+}
+
+type KafkaTransformator struct{}
+
+//transforms the pipes of Kafka hosts to Strimzi KafkaTopic manifests and a script creating them with kafka-topics.sh
+func (t *KafkaTransformator) Transform(model *models.Model, options Options) (string, error) {
+	var topics []kafkaTopic
+	for _, queue := range utils.SortQueuesByName(model.Pipes.Queues) {
+		host := utils.FindHostByName(model.Hosts.PipeHosts, queue.Host)
+		if host != nil && host.Type == "Kafka" {
+			topics = append(topics, kafkaTopic{Name: queue.Name, Host: host, Settings: queue.KafkaSettings})
+		}
+	}
+	for _, topic := range utils.SortTopicsByName(model.Pipes.Topics) {
+		host := utils.FindHostByName(model.Hosts.PipeHosts, topic.Host)
+		if host != nil && host.Type == "Kafka" {
+			topics = append(topics, kafkaTopic{Name: topic.Name, Host: host, Settings: topic.KafkaSettings})
+		}
+	}
+
+	var sb strings.Builder
+	for i, topic := range topics {
+		resource, err := createKafkaTopicResource(topic, options)
+		if err != nil {
+			return "", err
+		}
+		encoder := yaml.NewEncoder(&sb)
+		err = encoder.Encode(resource)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode KafkaTopic: %w", err)
+		}
+		encoder.Close()
+		if i < len(topics)-1 {
+			sb.WriteString("---\n")
+		}
+	}
+
+	if options.WriteFile {
+		script, err := createKafkaTopicScript(topics, options)
+		if err != nil {
+			return "", err
+		}
+		err = os.WriteFile(options.OutputPath(KafkaScriptFile), []byte(script), 0755)
+		if err != nil {
+			return "", fmt.Errorf("failed to write Kafka topic script to file: %w", err)
+		}
+		err = os.WriteFile(options.OutputPath(KafkaModelFile), []byte(sb.String()), 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to write Kafka model to file: %w", err)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+//returns the name of the Strimzi cluster of a host, the cluster config or the sanitized host name
+func strimziClusterName(host *models.Host) string {
+	if cluster := host.AdditionalProps["cluster"]; cluster != "" {
+		return cluster
+	}
+	return utils.SanitizeName(host.Name)
+}
+
+func createKafkaTopicResource(topic kafkaTopic, options Options) (map[string]interface{}, error) {
+	metadata := map[string]interface{}{
+		"name": utils.SanitizeName(topic.Name),
+		"labels": map[string]interface{}{
+			strimziClusterLabel: strimziClusterName(topic.Host),
+			ManagedByLabel:      "eicoda",
+			DeploymentLabel:     options.DeploymentName,
+		},
+	}
+	if namespace := topic.Host.AdditionalProps["namespace"]; namespace != "" {
+		metadata["namespace"] = namespace
+	}
+
+	//kafka topic names may contain characters kubernetes names may not, so the name is set explicitly
+	spec := map[string]interface{}{
+		"topicName": topic.Name,
+	}
+	if topic.Settings.Partitions > 0 {
+		spec["partitions"] = topic.Settings.Partitions
+	}
+	if topic.Settings.ReplicationFactor > 0 {
+		spec["replicas"] = topic.Settings.ReplicationFactor
+	}
+	if topic.Settings.Retention != "" {
+		retention, err := topic.Settings.RetentionMilliseconds()
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", topic.Name, err)
+		}
+		spec["config"] = map[string]interface{}{
+			"retention.ms": retention,
+		}
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "kafka.strimzi.io/v1beta2",
+		"kind":       "KafkaTopic",
+		"metadata":   metadata,
+		"spec":       spec,
+	}, nil
+}
+
+//returns a script that creates the topics on clusters without the Strimzi topic operator. Credentials of SASL hosts
+//are not part of the script, kafka-topics.sh reads them from the file KAFKA_COMMAND_CONFIG points to
+func createKafkaTopicScript(topics []kafkaTopic, options Options) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString(fmt.Sprintf("# generated by EICODA for deployment %s, creates the kafka topics of the model\n", options.DeploymentName))
+	sb.WriteString("set -e\n")
+	sb.WriteString("COMMAND_CONFIG=${KAFKA_COMMAND_CONFIG:+--command-config $KAFKA_COMMAND_CONFIG}\n")
+
+	for _, topic := range topics {
+		command := fmt.Sprintf("kafka-topics.sh --bootstrap-server %s $COMMAND_CONFIG --create --if-not-exists --topic %s",
+			shellQuote(strings.ReplaceAll(topic.Host.AdditionalProps["bootstrap_servers"], " ", "")), shellQuote(topic.Name))
+		if topic.Settings.Partitions > 0 {
+			command += fmt.Sprintf(" --partitions %d", topic.Settings.Partitions)
+		}
+		if topic.Settings.ReplicationFactor > 0 {
+			command += fmt.Sprintf(" --replication-factor %d", topic.Settings.ReplicationFactor)
+		}
+		if topic.Settings.Retention != "" {
+			retention, err := topic.Settings.RetentionMilliseconds()
+			if err != nil {
+				return "", fmt.Errorf("topic %s: %w", topic.Name, err)
+			}
+			command += fmt.Sprintf(" --config retention.ms=%d", retention)
+		}
+		sb.WriteString(fmt.Sprintf("\n# %s on host %s\n%s\n", topic.Name, topic.Host.Name, command))
+	}
+	return sb.String(), nil
+}
+
+//quotes a value as a single argument of a shell command
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -11,16 +11,22 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+//file name of the generated Kubernetes model
+const KubernetesModelFile = "kubernetesModel.yaml"
+
+//label that marks resources as managed by EICODA so that removed filters can be pruned
+const ManagedByLabel = "app.kubernetes.io/managed-by"
+
 type KubernetesTransformator struct{}
 
-func (t *KubernetesTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+func (t *KubernetesTransformator) Transform(model *models.Model, options Options) (string, error) {
 	var resources []interface{}
 
-	for _, filter := range model.Filters {
+	for _, filter := range utils.SortFiltersByName(model.Filters) {
 		host := utils.FindHostByName(model.Hosts.FilterHosts, filter.Host)
 		if host != nil && host.Type == "Kubernetes" {
 			image := utils.FindArtifactImage(model.DeploymentArtifacts, filter.Artifact)
-			deployment, configMap := createKubernetesDeployment(model, filter, image, baseDir)
+			deployment, configMap := createKubernetesDeployment(model, filter, image, options)
 			resources = append(resources, deployment)
 			if configMap != nil {
 				resources = append(resources, configMap)
@@ -42,8 +48,8 @@ func (t *KubernetesTransformator) Transform(model *models.Model, writeFile bool,
 	}
 
 	//write to file if writeFile is true
-	if writeFile {
-		outputPath := filepath.Join(".", "kubernetesModel.yaml")
+	if options.WriteFile {
+		outputPath := options.OutputPath(KubernetesModelFile)
 		err := os.WriteFile(outputPath, []byte(sb.String()), 0644)
 		if err != nil {
 			return "", fmt.Errorf("failed to write Kubernetes model to file: %w", err)
@@ -53,72 +59,57 @@ func (t *KubernetesTransformator) Transform(model *models.Model, writeFile bool,
 	return sb.String(), nil
 }
 
-func createKubernetesDeployment(model *models.Model, filter models.Filter, image string, baseDir string) (map[string]interface{}, map[string]interface{}) {
-	name := utils.SanitizeName(filter.Name)
+func createKubernetesDeployment(model *models.Model, filter models.Filter, image string, options Options) (map[string]interface{}, map[string]interface{}) {
+	name := kubernetesResourceName(filter, options)
+	labels := map[string]interface{}{
+		ManagedByLabel:  "eicoda",
+		DeploymentLabel: options.DeploymentName,
+	}
 	envVars := []map[string]interface{}{}
 	volumeMounts := []map[string]interface{}{}
 	volumes := []map[string]interface{}{}
 
+	//credentials are read from secrets and referenced as $(NAME) in the connection strings, so they have to come first
+	credentialHosts := make(map[string]bool)
 	for _, mapping := range filter.Mappings {
-		parts := strings.Split(mapping, ":")
-		if len(parts) == 2 {
-			pipeMapping := parts[1]
-			var pipeName, routingKey string
-
-			//check if the mapping has a routing key
-			if strings.Contains(pipeMapping, "->") {
-				pipeParts := strings.Split(pipeMapping, "->")
-				if len(pipeParts) == 2 {
-					pipeName = pipeParts[0]
-					routingKey = pipeParts[1]
-				} else {
-					pipeName = pipeMapping
-				}
-			} else {
-				pipeName = pipeMapping
-			}
-
-			var pipeType string
-			var pipeHost *models.Host
-			var pipeProtocol string
-
-			queue := utils.FindQueueByName(model.Pipes.Queues, pipeName)
-			if queue != nil {
-				pipeType = "queue"
-				pipeHost = utils.FindHostByName(model.Hosts.PipeHosts, queue.Host)
-				pipeProtocol = queue.Protocol
-			} else {
-				topic := utils.FindTopicByName(model.Pipes.Topics, pipeName)
-				if topic != nil {
-					pipeType = "topic"
-					pipeHost = utils.FindHostByName(model.Hosts.PipeHosts, topic.Host)
-					pipeProtocol = topic.Protocol
-				}
-			}
-
-			if pipeHost != nil {
-				value := fmt.Sprintf("%s://%s:%s@%s:%s,%s,%s",
-					pipeProtocol,
-					pipeHost.AdditionalProps["username"],
-					pipeHost.AdditionalProps["password"],
-					strings.ToLower(pipeHost.Type),
-					pipeHost.AdditionalProps["messaging_port"],
-					pipeName,
-					pipeType,
-				)
+		pipe := resolveMappedPipe(model, filter, mapping)
+		if pipe == nil || credentialHosts[pipe.Host.Name] {
+			continue
+		}
+		credentialHosts[pipe.Host.Name] = true
+
+		for _, prop := range pipe.credentialProps() {
+			secretName, key := kubernetesSecretKeyRef(pipe.Host, prop, options)
+			envVars = append(envVars, map[string]interface{}{
+				"name": SecretEnvName(pipe.Host, prop),
+				"valueFrom": map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{
+						"name": secretName,
+						"key":  key,
+					},
+				},
+			})
+		}
+	}
 
-				envVars = append(envVars, map[string]interface{}{
-					"name":  parts[0],
-					"value": value,
-				})
+	for _, mapping := range filter.Mappings {
+		pipe := resolveMappedPipe(model, filter, mapping)
+		if pipe == nil {
+			continue
+		}
 
-				if routingKey != "" {
-					envVars = append(envVars, map[string]interface{}{
-						"name":  fmt.Sprintf("%sRoutingKey", parts[0]),
-						"value": routingKey,
-					})
-				}
-			}
+		credentialRef := func(prop string) string {
+			return "$(" + SecretEnvName(pipe.Host, prop) + ")"
+		}
+		hostAddress := kubernetesServiceName(pipe.Host)
+		if pipe.Host.Type == "Kafka" {
+			hostAddress = kafkaBootstrapServers(pipe.Host, "")
+		}
+		for _, env := range mappingEnvVars(mapping, pipe, hostAddress, credentialRef) {
+			envVars = append(envVars, map[string]interface{}{
+				"name":  env.Name,
+				"value": env.Value,
+			})
 		}
 	}
 
@@ -130,8 +121,8 @@ func createKubernetesDeployment(model *models.Model, filter models.Filter, image
 			if !exists {
 				value = fmt.Sprintf("%v", config.Default)
 			}
-			if config.File {
-				filePath := filepath.Join(baseDir, value)
+			if config.IsFile() {
+				filePath := filepath.Join(options.BaseDir, value)
 				fileContent, err := os.ReadFile(filePath)
 				if err != nil {
 					fmt.Printf("failed to read file %s: %v", filePath, err)
@@ -143,7 +134,8 @@ func createKubernetesDeployment(model *models.Model, filter models.Filter, image
 					"apiVersion": "v1",
 					"kind":       "ConfigMap",
 					"metadata": map[string]interface{}{
-						"name": configMapName,
+						"name":   configMapName,
+						"labels": labels,
 					},
 					"data": map[string]interface{}{
 						config.Name: string(fileContent),
@@ -165,31 +157,41 @@ func createKubernetesDeployment(model *models.Model, filter models.Filter, image
 			} else {
 				envVars = append(envVars, map[string]interface{}{
 					"name":  config.Name,
-					"value": utils.ConvertToProperType(value),
+					"value": utils.RenderConfigValue(config, value),
 				})
 			}
 		}
 	}
 
+	//changes of mounted ConfigMaps do not restart pods, so their hash is added as annotation to trigger a rollout
+	templateMetadata := map[string]interface{}{
+		"labels": map[string]interface{}{
+			"app":           name,
+			DeploymentLabel: options.DeploymentName,
+		},
+	}
+	if configHash := utils.HashFileConfigs(filterType, filter, options.BaseDir); configHash != "" {
+		templateMetadata["annotations"] = map[string]interface{}{
+			ConfigHashLabel: configHash,
+		}
+	}
+
 	deployment := map[string]interface{}{
 		"apiVersion": "apps/v1",
 		"kind":       "Deployment",
 		"metadata": map[string]interface{}{
-			"name": name,
+			"name":   name,
+			"labels": labels,
 		},
 		"spec": map[string]interface{}{
-			"replicas": 1,
+			"replicas": filter.ReplicaCount(),
 			"selector": map[string]interface{}{
 				"matchLabels": map[string]interface{}{
 					"app": name,
 				},
 			},
 			"template": map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"labels": map[string]interface{}{
-						"app": name,
-					},
-				},
+				"metadata": templateMetadata,
 				"spec": map[string]interface{}{
 					"containers": []map[string]interface{}{
 						{
@@ -207,3 +209,20 @@ func createKubernetesDeployment(model *models.Model, filter models.Filter, image
 
 	return deployment, configMap
 }
+
+//returns the name of the kubernetes resources of a filter, prefixed with the deployment name for named deployments so that they do not collide
+func kubernetesResourceName(filter models.Filter, options Options) string {
+	if options.DeploymentName == "" || options.DeploymentName == DefaultDeploymentName {
+		return utils.SanitizeName(filter.Name)
+	}
+	return utils.SanitizeName(options.DeploymentName + "-" + filter.Name)
+}
+
+//returns the kubernetes service the filters reach a pipe host with, the service prop of the host or its sanitized name,
+//so filters of different brokers do not end up at the same one
+func kubernetesServiceName(host *models.Host) string {
+	if service := host.AdditionalProps["service"]; service != "" {
+		return service
+	}
+	return utils.SanitizeName(host.Name)
+}
@@ -0,0 +1,233 @@
+package transformators
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"eicoda/models"
+	"eicoda/utils"
+)
+
+//file name of the generated configurations and ACLs of all Mosquitto hosts, keyed by host name
+const MosquittoModelFile = "mosquitto.json"
+
+//file names of the configuration, ACLs and password file of a single host, formatted with the sanitized host name
+const (
+	MosquittoConfigFile   = "mosquitto-%s.conf"
+	MosquittoAclFile      = "mosquitto-%s.acl"
+	MosquittoPasswordFile = "mosquitto-%s.passwd"
+)
+
+//directory the broker reads its configuration from, as in the official Mosquitto image
+const mosquittoConfigDir = "/mosquitto/config"
+
+//iterations of the PBKDF2 hashes in the password file, the default of mosquitto_passwd
+const mosquittoHashIterations = 101
+
+//configuration and ACLs of a Mosquitto host
+type MosquittoHostConfig struct {
+	Config string `json:"config"`
+	Acl    string `json:"acl"`
+}
+
+type MosquittoTransformator struct{}
+
+//transforms the model to a Mosquitto configuration with an ACL per filter for every Mosquitto host. Every filter
+//authenticates as its own user and may only read the pipes it consumes and write the pipes it produces. The password
+//file of a host is only written if the credentials are resolved, i.e. when the model is deployed
+func (t *MosquittoTransformator) Transform(model *models.Model, options Options) (string, error) {
+	all := make(map[string]MosquittoHostConfig)
+	for i := range model.Hosts.PipeHosts {
+		host := &model.Hosts.PipeHosts[i]
+		if host.Type != "Mosquitto" {
+			continue
+		}
+
+		name := utils.SanitizeName(host.Name)
+		acl := createMosquittoAcl(model, host)
+
+		//the broker reads the ACLs from a separate file, so their hash makes changes visible in the configuration
+		config := fmt.Sprintf(`# generated by EICODA for deployment %s and host %s
+# acl hash: %s
+per_listener_settings false
+listener %s
+allow_anonymous false
+password_file %s/%s
+acl_file %s/%s
+`, options.DeploymentName, host.Name, utils.HashContent([]byte(acl)), host.AdditionalProps["messaging_port"],
+			mosquittoConfigDir, fmt.Sprintf(MosquittoPasswordFile, name), mosquittoConfigDir, fmt.Sprintf(MosquittoAclFile, name))
+		all[host.Name] = MosquittoHostConfig{Config: config, Acl: acl}
+
+		if options.WriteFile {
+			err := os.WriteFile(options.OutputPath(fmt.Sprintf(MosquittoAclFile, name)), []byte(acl), 0644)
+			if err != nil {
+				return "", fmt.Errorf("failed to write Mosquitto ACLs to file: %w", err)
+			}
+			err = os.WriteFile(options.OutputPath(fmt.Sprintf(MosquittoConfigFile, name)), []byte(config), 0644)
+			if err != nil {
+				return "", fmt.Errorf("failed to write Mosquitto configuration to file: %w", err)
+			}
+			if passwd, ok := createMosquittoPasswordFile(model, host, options.Secrets); ok {
+				err = os.WriteFile(options.OutputPath(fmt.Sprintf(MosquittoPasswordFile, name)), []byte(passwd), 0600)
+				if err != nil {
+					return "", fmt.Errorf("failed to write Mosquitto password file: %w", err)
+				}
+			}
+		}
+	}
+
+	//encoding/json sorts the host names, so the content is stable, a model without Mosquitto hosts results in {}
+	content, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Mosquitto configurations: %w", err)
+	}
+	if options.WriteFile {
+		err = os.WriteFile(options.OutputPath(MosquittoModelFile), append(content, '\n'), 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to write Mosquitto model to file: %w", err)
+		}
+	}
+
+	return string(content) + "\n", nil
+}
+
+//returns the user a filter authenticates with at a Mosquitto host
+func MosquittoUser(filter models.Filter) string {
+	return utils.SanitizeName(filter.Name)
+}
+
+//returns the credential property holding the password of a filter at a Mosquitto host
+func mosquittoPasswordProp(user string) string {
+	return user + "_password"
+}
+
+//derives the password of a filter from the password of its Mosquitto host, so every filter gets its own password
+//without any of them being stored
+func mosquittoPassword(hostPassword string, user string) string {
+	mac := hmac.New(sha256.New, []byte(hostPassword))
+	mac.Write([]byte(user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//returns the filters with mappings to pipes of a Mosquitto host sorted by name
+func mosquittoFilters(model *models.Model, host *models.Host) []models.Filter {
+	var filters []models.Filter
+	for _, filter := range utils.SortFiltersByName(model.Filters) {
+		for _, mapping := range filter.Mappings {
+			pipe := resolveMappedPipe(model, filter, mapping)
+			if pipe != nil && pipe.Host.Name == host.Name {
+				filters = append(filters, filter)
+				break
+			}
+		}
+	}
+	return filters
+}
+
+//returns the password file of a host with an entry per filter in the format of mosquitto_passwd. Returns false
+//if the passwords of the filters are not among the resolved secrets
+func createMosquittoPasswordFile(model *models.Model, host *models.Host, secrets []models.Secret) (string, bool) {
+	passwords := make(map[string]string)
+	for _, secret := range secrets {
+		passwords[secret.EnvName] = secret.Value
+	}
+
+	var sb strings.Builder
+	for _, filter := range mosquittoFilters(model, host) {
+		user := MosquittoUser(filter)
+		password, exists := passwords[SecretEnvName(host, mosquittoPasswordProp(user))]
+		if !exists {
+			return "", false
+		}
+
+		//the salt is derived from the password, so the file only changes with the credentials
+		mac := hmac.New(sha256.New, []byte(password))
+		mac.Write([]byte("salt"))
+		salt := mac.Sum(nil)[:12]
+
+		hash := pbkdf2Sha512([]byte(password), salt, mosquittoHashIterations, sha512.Size)
+		sb.WriteString(fmt.Sprintf("%s:$7$%d$%s$%s\n", user, mosquittoHashIterations,
+			base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(hash)))
+	}
+	return sb.String(), true
+}
+
+//derives a key with PBKDF2 and HMAC-SHA512 as described in RFC 8018
+func pbkdf2Sha512(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha512.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
+
+//grants every filter read access to the pipes it consumes and write access to the pipes it produces
+func createMosquittoAcl(model *models.Model, host *models.Host) string {
+	artifacts := make(map[string]models.DeploymentArtifact)
+	for _, artifact := range model.DeploymentArtifacts {
+		artifacts[artifact.Name] = artifact
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# generated by EICODA, one user per filter\n")
+	for _, filter := range mosquittoFilters(model, host) {
+		access := make(map[string]string)
+		for _, mapping := range filter.Mappings {
+			pipe := resolveMappedPipe(model, filter, mapping)
+			if pipe == nil || pipe.Host.Name != host.Name {
+				continue
+			}
+			direction, _ := artifacts[filter.Artifact].PortDirection(mapping.Port)
+			access[pipe.Name] = mergeMosquittoAccess(access[pipe.Name], direction)
+		}
+
+		var topics []string
+		for topic := range access {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+
+		sb.WriteString(fmt.Sprintf("\n# filter %s\nuser %s\n", filter.Name, MosquittoUser(filter)))
+		for _, topic := range topics {
+			sb.WriteString(fmt.Sprintf("topic %s %s\n", access[topic], topic))
+		}
+	}
+	return sb.String()
+}
+
+//combines the access of several mappings of a filter to the same pipe
+func mergeMosquittoAccess(current string, direction string) string {
+	access := "readwrite"
+	switch direction {
+	case models.DirectionInput:
+		access = "read"
+	case models.DirectionOutput:
+		access = "write"
+	}
+	if current == "" || current == access {
+		return access
+	}
+	return "readwrite"
+}
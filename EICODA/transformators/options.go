@@ -0,0 +1,33 @@
+package transformators
+
+import (
+	"path/filepath"
+
+	"eicoda/models"
+)
+
+//name of the deployment used when none is given
+const DefaultDeploymentName = "default"
+
+//label marking generated resources with the deployment they belong to
+const DeploymentLabel = "eicoda.deployment"
+
+//holds settings that control how a transformator generates and writes its model
+type Options struct {
+	WriteFile bool
+	//directory of the deployment model, file backed configs like criteria files are resolved relative to it
+	BaseDir string
+	//directory the generated model is written to
+	OutputDir string
+	//identity of the deployment, used to keep resources of several deployments apart
+	DeploymentName string
+	//copies file backed configs next to the generated model and references them relatively, so the output can be committed
+	PortableFiles bool
+	//resolved credentials of the pipe hosts, only set when the model is deployed
+	Secrets []models.Secret
+}
+
+//returns the path a generated model with the given file name is written to
+func (o Options) OutputPath(fileName string) string {
+	return filepath.Join(o.OutputDir, fileName)
+}
@@ -0,0 +1,272 @@
+package transformators
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"eicoda/models"
+	"eicoda/utils"
+)
+
+//file name of the generated definitions of all RabbitMQ hosts with their management endpoints, keyed by host name
+const RabbitMqDefinitionsModelFile = "rabbitMqDefinitions.json"
+
+//file names of the definitions of a single host, formatted with the sanitized host name. The users file is a
+//template, the credentials are only referenced as ${EICODA_<HOST>_USERNAME} and ${EICODA_<HOST>_PASSWORD}
+const (
+	RabbitMqDefinitionsFile = "rabbitmq-definitions-%s.json"
+	RabbitMqUsersFile       = "rabbitmq-users-%s.json"
+)
+
+//definitions in the format of the RabbitMQ definitions export, as loaded with load_definitions or imported
+//through POST /api/definitions
+type RabbitMqDefinitions struct {
+	Users       []RabbitMqUserDefinition       `json:"users,omitempty"`
+	Vhosts      []RabbitMqVhostDefinition      `json:"vhosts,omitempty"`
+	Permissions []RabbitMqPermissionDefinition `json:"permissions,omitempty"`
+	Queues      []RabbitMqQueueDefinition      `json:"queues,omitempty"`
+	Exchanges   []RabbitMqExchangeDefinition   `json:"exchanges,omitempty"`
+	Bindings    []RabbitMqBindingDefinition    `json:"bindings,omitempty"`
+	Policies    []RabbitMqPolicyDefinition     `json:"policies,omitempty"`
+}
+
+//the definitions of a host and the endpoint of its management API they are applied to
+type RabbitMqHostDefinitions struct {
+	Endpoint    string              `json:"endpoint"`
+	Definitions RabbitMqDefinitions `json:"definitions"`
+}
+
+type RabbitMqUserDefinition struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Tags     string `json:"tags"`
+}
+
+type RabbitMqVhostDefinition struct {
+	Name string `json:"name"`
+}
+
+type RabbitMqPermissionDefinition struct {
+	User      string `json:"user"`
+	Vhost     string `json:"vhost"`
+	Configure string `json:"configure"`
+	Write     string `json:"write"`
+	Read      string `json:"read"`
+}
+
+type RabbitMqQueueDefinition struct {
+	Name       string                 `json:"name"`
+	Vhost      string                 `json:"vhost"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+type RabbitMqExchangeDefinition struct {
+	Name       string                 `json:"name"`
+	Vhost      string                 `json:"vhost"`
+	Type       string                 `json:"type"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Internal   bool                   `json:"internal"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+type RabbitMqBindingDefinition struct {
+	Source          string                 `json:"source"`
+	Vhost           string                 `json:"vhost"`
+	Destination     string                 `json:"destination"`
+	DestinationType string                 `json:"destination_type"`
+	RoutingKey      string                 `json:"routing_key"`
+	Arguments       map[string]interface{} `json:"arguments"`
+}
+
+type RabbitMqPolicyDefinition struct {
+	Name       string                 `json:"name"`
+	Vhost      string                 `json:"vhost"`
+	Pattern    string                 `json:"pattern"`
+	ApplyTo    string                 `json:"apply-to"`
+	Definition map[string]interface{} `json:"definition"`
+	Priority   int                    `json:"priority"`
+}
+
+type RabbitMqDefinitionsTransformator struct{}
+
+//transforms the pipes of every RabbitMQ host to RabbitMQ definitions, an alternative to the Terraform model on
+//machines without terraform. The definitions of a host can be loaded as they are, its users file only after the
+//credential placeholders are substituted, e.g. with envsubst
+func (t *RabbitMqDefinitionsTransformator) Transform(model *models.Model, options Options) (string, error) {
+	var hosts []*models.Host
+	for i := range model.Hosts.PipeHosts {
+		if model.Hosts.PipeHosts[i].Type == "RabbitMQ" {
+			hosts = append(hosts, &model.Hosts.PipeHosts[i])
+		}
+	}
+
+	all := make(map[string]RabbitMqHostDefinitions)
+	for _, host := range hosts {
+		definitions, users := CreateRabbitMqDefinitions(model, host)
+		if definitions.isEmpty() {
+			continue
+		}
+		all[host.Name] = RabbitMqHostDefinitions{Endpoint: RabbitMqManagementEndpoint(host), Definitions: definitions}
+
+		if options.WriteFile {
+			name := utils.SanitizeName(host.Name)
+			if err := writeRabbitMqDefinitions(options.OutputPath(fmt.Sprintf(RabbitMqDefinitionsFile, name)), definitions); err != nil {
+				return "", err
+			}
+			if err := writeRabbitMqDefinitions(options.OutputPath(fmt.Sprintf(RabbitMqUsersFile, name)), users); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	//encoding/json sorts the host names, so the content is stable
+	content, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode RabbitMQ definitions: %w", err)
+	}
+	if options.WriteFile {
+		err = os.WriteFile(options.OutputPath(RabbitMqDefinitionsModelFile), append(content, '\n'), 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to write RabbitMQ definitions to file: %w", err)
+		}
+	}
+
+	return string(content) + "\n", nil
+}
+
+//returns the definitions of the pipes and bindings of a host and the users and permissions the filters connect
+//with. Exclusive queues are declared by their filters and MQTT topics are routing keys of the MQTT exchange, so
+//neither is part of the definitions
+func CreateRabbitMqDefinitions(model *models.Model, host *models.Host) (RabbitMqDefinitions, RabbitMqDefinitions) {
+	var definitions RabbitMqDefinitions
+	hostVhost := rabbitMqHostVhost(host)
+	var vhosts []string
+	addVhost := func(vhost string) {
+		for _, added := range vhosts {
+			if added == vhost {
+				return
+			}
+		}
+		vhosts = append(vhosts, vhost)
+	}
+
+	for _, queue := range utils.SortQueuesByName(model.Pipes.Queues) {
+		if queue.Host != host.Name {
+			continue
+		}
+		vhost := queue.Configs.VhostName(host.AdditionalProps["vhost"])
+		addVhost(vhost)
+		if queue.Configs == nil || !queue.Configs.Exclusive {
+			definitions.Queues = append(definitions.Queues, RabbitMqQueueDefinition{
+				Name:       queue.Name,
+				Vhost:      vhost,
+				Durable:    queue.Configs.IsDurable(),
+				AutoDelete: queue.Configs != nil && queue.Configs.AutoDelete,
+				Arguments:  queue.Configs.Arguments(),
+			})
+		}
+		if queue.Configs != nil {
+			if definition := queue.Configs.PolicyDefinition(); len(definition) > 0 {
+				definitions.Policies = append(definitions.Policies, RabbitMqPolicyDefinition{
+					Name:       "eicoda-" + queue.Name,
+					Vhost:      vhost,
+					Pattern:    "^" + regexp.QuoteMeta(queue.Name) + "$",
+					ApplyTo:    "queues",
+					Definition: definition,
+					Priority:   1,
+				})
+			}
+		}
+		if queue.Protocol == "mqtt" {
+			definitions.Bindings = append(definitions.Bindings, RabbitMqBindingDefinition{
+				Source:          mqttExchange,
+				Vhost:           vhost,
+				Destination:     queue.Name,
+				DestinationType: "queue",
+				RoutingKey:      strings.ReplaceAll(queue.Name, "/", "."),
+				Arguments:       map[string]interface{}{},
+			})
+		}
+	}
+
+	for _, topic := range utils.SortTopicsByName(model.Pipes.Topics) {
+		if topic.Host != host.Name {
+			continue
+		}
+		addVhost(hostVhost)
+		if topic.Protocol == "mqtt" {
+			continue
+		}
+		definitions.Exchanges = append(definitions.Exchanges, RabbitMqExchangeDefinition{
+			Name:      topic.Name,
+			Vhost:     hostVhost,
+			Type:      topic.Exchange(),
+			Durable:   true,
+			Arguments: map[string]interface{}{},
+		})
+	}
+
+	for _, binding := range model.Pipes.Bindings {
+		source := utils.FindTopicByName(model.Pipes.Topics, binding.Source)
+		if source == nil || source.Host != host.Name {
+			continue
+		}
+		destinationType := "exchange"
+		if utils.FindQueueByName(model.Pipes.Queues, binding.Destination) != nil {
+			destinationType = "queue"
+		}
+		arguments := make(map[string]interface{})
+		for name, value := range binding.Arguments {
+			arguments[name] = value
+		}
+		definitions.Bindings = append(definitions.Bindings, RabbitMqBindingDefinition{
+			Source:          binding.Source,
+			Vhost:           hostVhost,
+			Destination:     binding.Destination,
+			DestinationType: destinationType,
+			RoutingKey:      binding.RoutingKey,
+			Arguments:       arguments,
+		})
+	}
+
+	//the vhosts are only declared, importing definitions does not remove vhosts shared with other applications.
+	//the user of the host also provisions the broker through the management API, so it stays an administrator
+	var users RabbitMqDefinitions
+	username := "${" + SecretEnvName(host, "username") + "}"
+	users.Users = []RabbitMqUserDefinition{{Name: username, Password: "${" + SecretEnvName(host, "password") + "}", Tags: "administrator"}}
+	for _, vhost := range vhosts {
+		definitions.Vhosts = append(definitions.Vhosts, RabbitMqVhostDefinition{Name: vhost})
+		users.Vhosts = append(users.Vhosts, RabbitMqVhostDefinition{Name: vhost})
+		users.Permissions = append(users.Permissions, RabbitMqPermissionDefinition{User: username, Vhost: vhost, Configure: ".*", Write: ".*", Read: ".*"})
+	}
+
+	return definitions, users
+}
+
+//returns the URL of the management API of a host, the terraform provider uses the same endpoint
+func RabbitMqManagementEndpoint(host *models.Host) string {
+	return fmt.Sprintf("http://%s:%s", host.AdditionalProps["host_address"], host.AdditionalProps["management_port"])
+}
+
+//returns true if the host has no pipes
+func (d RabbitMqDefinitions) isEmpty() bool {
+	return len(d.Vhosts) == 0
+}
+
+func writeRabbitMqDefinitions(path string, definitions RabbitMqDefinitions) error {
+	content, err := json.MarshalIndent(definitions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode RabbitMQ definitions: %w", err)
+	}
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write RabbitMQ definitions to file: %w", err)
+	}
+	return nil
+}
@@ -1,17 +1,35 @@
 package transformators
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"eicoda/models"
 	"eicoda/utils"
 )
 
+//file name of the generated Terraform model for RabbitMQ
+const RabbitMqModelFile = "rabbitMqModel.tf"
+
+//file name of the generated configuration of the MQTT plugin of a RabbitMQ host, formatted with the sanitized host name
+const RabbitMqMqttConfigFile = "rabbitmq-mqtt-%s.conf"
+
+//exchange the RabbitMQ MQTT plugin publishes to
+const mqttExchange = "amq.topic"
+
+var invalidAliasChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+//characters terraform does not allow in resource names, e.g. the / separating the topic levels of MQTT queues
+var invalidResourceChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
 type RabbitMqTransformator struct{}
 
-func (t *RabbitMqTransformator) Transform(model *models.Model, writeFile bool, baseDir string) (string, error) {
+//transforms the queues and topics of all RabbitMQ hosts to a Terraform model. Every host gets a provider with
+//its own alias and credentials, and the resources are bound to the provider of their host
+func (t *RabbitMqTransformator) Transform(model *models.Model, options Options) (string, error) {
 	terraformResources := `
 terraform {
   required_providers {
@@ -21,84 +39,325 @@ terraform {
     }
   }
 }
-
-provider "rabbitmq" {
-  endpoint  = "%s"
-  username  = "%s"
-  password  = "%s"
-}
 `
 
-	var endpoint, username, password string
-	for _, host := range model.Hosts.PipeHosts {
-		if host.Type == "RabbitMQ" {
-			hostAddress := host.AdditionalProps["host_address"]
-			managementPort := host.AdditionalProps["management_port"]
-			endpoint = fmt.Sprintf("http://%s:%s", hostAddress, managementPort)
-			username = host.AdditionalProps["username"]
-			password = host.AdditionalProps["password"]
-			break
+	var resources string
+	var hosts []*models.Host
+	mqttHosts := make(map[string]bool)
+	vhosts := make(map[string][]string)
+	addHost := func(host *models.Host) {
+		for _, added := range hosts {
+			if added.Name == host.Name {
+				return
+			}
 		}
+		hosts = append(hosts, host)
+	}
+	addVhost := func(host *models.Host, vhost string) {
+		for _, added := range vhosts[host.Name] {
+			if added == vhost {
+				return
+			}
+		}
+		vhosts[host.Name] = append(vhosts[host.Name], vhost)
 	}
 
-	terraformResources = fmt.Sprintf(terraformResources, endpoint, username, password)
-
-	for _, pipe := range model.Pipes.Queues {
+	for _, pipe := range utils.SortQueuesByName(model.Pipes.Queues) {
 		host := utils.FindHostByName(model.Hosts.PipeHosts, pipe.Host)
 		if host != nil && host.Type == "RabbitMQ" {
-			resource := createRabbitMqQueueResource(pipe, host)
-			terraformResources += resource + "\n"
+			addHost(host)
+			addVhost(host, pipe.Configs.VhostName(host.AdditionalProps["vhost"]))
+			resources += createRabbitMqQueueResource(pipe, host) + "\n"
+			if pipe.Protocol == "mqtt" {
+				resources += createRabbitMqMqttBindingResource(pipe, host) + "\n"
+				mqttHosts[host.Name] = true
+			}
 		}
 	}
 
-	for _, topic := range model.Pipes.Topics {
+	for _, topic := range utils.SortTopicsByName(model.Pipes.Topics) {
 		host := utils.FindHostByName(model.Hosts.PipeHosts, topic.Host)
 		if host != nil && host.Type == "RabbitMQ" {
-			resource := createRabbitMqTopicResource(topic, host)
-			terraformResources += resource + "\n"
+			addHost(host)
+			addVhost(host, rabbitMqHostVhost(host))
+			//MQTT topics are routing keys of the exchange of the MQTT plugin and need no exchange of their own
+			if topic.Protocol == "mqtt" {
+				mqttHosts[host.Name] = true
+				continue
+			}
+			resources += createRabbitMqTopicResource(topic, host) + "\n"
 		}
 	}
 
-	if writeFile {
-		outputPath := "rabbitMqModel.tf"
+	//the parser ensures that both ends of a binding are pipes of the same RabbitMQ host
+	bindingNames := make(map[string]int)
+	for _, binding := range model.Pipes.Bindings {
+		source := utils.FindTopicByName(model.Pipes.Topics, binding.Source)
+		if source == nil {
+			continue
+		}
+		host := utils.FindHostByName(model.Hosts.PipeHosts, source.Host)
+		if host == nil || host.Type != "RabbitMQ" {
+			continue
+		}
+		resourceName := terraformResourceName(binding.Source+"_to_"+binding.Destination)
+		bindingNames[resourceName]++
+		if count := bindingNames[resourceName]; count > 1 {
+			resourceName = fmt.Sprintf("%s_%d", resourceName, count)
+		}
+		resources += createRabbitMqBindingResource(model, binding, resourceName, host) + "\n"
+	}
+
+	//the credentials are sensitive variables that the terraform plugin sets as TF_VAR_ environment variables
+	for _, host := range hosts {
+		terraformResources += createRabbitMqProvider(host)
+		for _, vhost := range vhosts[host.Name] {
+			terraformResources += createRabbitMqVhostResource(host, vhost)
+		}
+	}
+	terraformResources += resources
+
+	if options.WriteFile {
+		outputPath := options.OutputPath(RabbitMqModelFile)
 		err := os.WriteFile(outputPath, []byte(terraformResources), 0644)
 		if err != nil {
 			return "", fmt.Errorf("failed to write RabbitMQ model to file: %w", err)
 		}
+		for _, host := range hosts {
+			if !mqttHosts[host.Name] {
+				continue
+			}
+			configPath := options.OutputPath(fmt.Sprintf(RabbitMqMqttConfigFile, utils.SanitizeName(host.Name)))
+			err = os.WriteFile(configPath, []byte(createRabbitMqMqttConfig(host)), 0644)
+			if err != nil {
+				return "", fmt.Errorf("failed to write RabbitMQ MQTT configuration to file: %w", err)
+			}
+		}
 	}
 
 	return terraformResources, nil
 }
 
+//returns the alias of the provider of a host, terraform identifiers only allow letters, digits, _ and -
+func rabbitMqProviderAlias(host *models.Host) string {
+	alias := invalidAliasChars.ReplaceAllString(strings.ToLower(host.Name), "_")
+	if alias == "" || alias[0] < 'a' || alias[0] > 'z' {
+		alias = "host_" + alias
+	}
+	return alias
+}
+
+//returns the vhost of a host, / if it sets none
+func rabbitMqHostVhost(host *models.Host) string {
+	if vhost := host.AdditionalProps["vhost"]; vhost != "" {
+		return vhost
+	}
+	return "/"
+}
+
+//returns the name of the vhost and permissions resources of a vhost of a host
+func rabbitMqVhostResourceName(host *models.Host, vhost string) string {
+	return rabbitMqProviderAlias(host) + "_" + strings.Trim(invalidAliasChars.ReplaceAllString(strings.ToLower(vhost), "_"), "_")
+}
+
+//returns the vhost attribute of the resources in a vhost. Other vhosts than the default vhost / are referenced
+//through the permissions of the user of the host, so terraform creates them and grants access before their pipes
+func rabbitMqVhostReference(host *models.Host, vhost string) string {
+	if vhost == "/" {
+		return `"/"`
+	}
+	return fmt.Sprintf("rabbitmq_permissions.%s.vhost", rabbitMqVhostResourceName(host, vhost))
+}
+
+//declares a vhost and the permissions of the user of the host in it. The default vhost / always exists and its
+//permissions are left to the broker
+func createRabbitMqVhostResource(host *models.Host, vhost string) string {
+	if vhost == "/" {
+		return ""
+	}
+	resourceName := rabbitMqVhostResourceName(host, vhost)
+	return fmt.Sprintf(`
+resource "rabbitmq_vhost" "%s" {
+  provider = rabbitmq.%s
+  name     = %q
+}
+
+resource "rabbitmq_permissions" "%s" {
+  provider = rabbitmq.%s
+  user     = var.%s
+  vhost    = rabbitmq_vhost.%s.name
+  permissions {
+    configure = ".*"
+    write     = ".*"
+    read      = ".*"
+  }
+}
+`, resourceName, rabbitMqProviderAlias(host), vhost, resourceName, rabbitMqProviderAlias(host), terraformVariableName(host, "username"), resourceName)
+}
+
+//returns the provider of a host with the variables of its credentials
+func createRabbitMqProvider(host *models.Host) string {
+	usernameVariable := terraformVariableName(host, "username")
+	passwordVariable := terraformVariableName(host, "password")
+	provider := fmt.Sprintf(`
+provider "rabbitmq" {
+  alias     = "%s"
+  endpoint  = "%s"
+  username  = var.%s
+  password  = var.%s
+}
+`, rabbitMqProviderAlias(host), RabbitMqManagementEndpoint(host), usernameVariable, passwordVariable)
+	return provider + createTerraformVariable(usernameVariable) + createTerraformVariable(passwordVariable)
+}
+
+//declares a sensitive variable, terraform does not print its value in plans and outputs
+func createTerraformVariable(name string) string {
+	return fmt.Sprintf(`
+variable "%s" {
+  type      = string
+  sensitive = true
+}
+`, name)
+}
+
+//renders a queue with the declaration settings of its configs, the runtime settings become a policy of the queue
+//unless they are passed as arguments. Exclusive queues belong to the connection of the filter declaring them and
+//only get their policy
 func createRabbitMqQueueResource(pipe models.Queue, host *models.Host) string {
-	resourceName := strings.ReplaceAll(pipe.Name, "-", "_")
-	resource := fmt.Sprintf(`
+	resourceName := terraformResourceName(pipe.Name)
+	var resource string
+	if pipe.Configs == nil || !pipe.Configs.Exclusive {
+		var arguments string
+		if args := pipe.Configs.Arguments(); len(args) > 0 {
+			//encoding/json sorts the keys, HCL accepts the JSON object as it is
+			encoded, _ := json.Marshal(args)
+			arguments = fmt.Sprintf("\n    arguments_json = jsonencode(%s)", encoded)
+		}
+		resource = fmt.Sprintf(`
 resource "rabbitmq_queue" "%s" {
+  provider  = rabbitmq.%s
   name      = "%s"
-  vhost     = "/"
+  vhost     = %s
   settings {
-    durable    = true
-    auto_delete = false
+    durable    = %t
+    auto_delete = %t%s
   }
 }
-`, resourceName, pipe.Name)
+`, resourceName, rabbitMqProviderAlias(host), pipe.Name, rabbitMqVhostReference(host, pipe.Configs.VhostName(host.AdditionalProps["vhost"])),
+			pipe.Configs.IsDurable(), pipe.Configs != nil && pipe.Configs.AutoDelete, arguments)
+	}
+
+	if pipe.Configs != nil {
+		if definition := pipe.Configs.PolicyDefinition(); len(definition) > 0 {
+			encoded, _ := json.Marshal(definition)
+			resource += fmt.Sprintf(`
+resource "rabbitmq_policy" "%s" {
+  provider = rabbitmq.%s
+  name     = "eicoda-%s"
+  vhost    = %s
+  policy {
+    pattern    = %q
+    priority   = 1
+    apply_to   = "queues"
+    definition = %s
+  }
+}
+`, resourceName, rabbitMqProviderAlias(host), pipe.Name, rabbitMqVhostReference(host, pipe.Configs.VhostName(host.AdditionalProps["vhost"])),
+				"^"+regexp.QuoteMeta(pipe.Name)+"$", encoded)
+		}
+	}
 
 	return resource
 }
 
+//renders a topic as exchange of its exchange type, topic if it sets none
 func createRabbitMqTopicResource(topic models.Topic, host *models.Host) string {
-	resourceName := strings.ReplaceAll(topic.Name, "-", "_")
+	resourceName := terraformResourceName(topic.Name)
 	resource := fmt.Sprintf(`
 resource "rabbitmq_exchange" "%s" {
-  name  = "%s"
-  vhost = "/"
+  provider = rabbitmq.%s
+  name     = "%s"
+  vhost    = %s
   settings {
-    type        = "topic"
+    type        = "%s"
     durable     = true
     auto_delete = false
   }
 }
-`, resourceName, topic.Name)
+`, resourceName, rabbitMqProviderAlias(host), topic.Name, rabbitMqVhostReference(host, rabbitMqHostVhost(host)), topic.Exchange())
 
 	return resource
 }
+
+//binds a queue or exchange to the exchange of a topic, the destination references its resource so terraform
+//creates it before the binding
+func createRabbitMqBindingResource(model *models.Model, binding models.Binding, resourceName string, host *models.Host) string {
+	destinationName := terraformResourceName(binding.Destination)
+	destination := fmt.Sprintf("rabbitmq_exchange.%s.name", destinationName)
+	destinationType := "exchange"
+	if utils.FindQueueByName(model.Pipes.Queues, binding.Destination) != nil {
+		destination = fmt.Sprintf("rabbitmq_queue.%s.name", destinationName)
+		destinationType = "queue"
+	}
+
+	var routing string
+	if binding.RoutingKey != "" {
+		routing = fmt.Sprintf("\n  routing_key      = %q", binding.RoutingKey)
+	}
+	if len(binding.Arguments) > 0 {
+		encoded, _ := json.Marshal(binding.Arguments)
+		routing += fmt.Sprintf("\n  arguments_json   = jsonencode(%s)", encoded)
+	}
+
+	return fmt.Sprintf(`
+resource "rabbitmq_binding" "%s" {
+  provider         = rabbitmq.%s
+  source           = rabbitmq_exchange.%s.name
+  vhost            = %s
+  destination      = %s
+  destination_type = "%s"%s
+}
+`, resourceName, rabbitMqProviderAlias(host), terraformResourceName(binding.Source), rabbitMqVhostReference(host, rabbitMqHostVhost(host)),
+		destination, destinationType, routing)
+}
+
+//binds a queue used over MQTT to the exchange of the MQTT plugin, so messages published to the topic of the
+//same name end up in it. MQTT separates topic levels with / while the routing keys of RabbitMQ use .
+func createRabbitMqMqttBindingResource(pipe models.Queue, host *models.Host) string {
+	resourceName := terraformResourceName(pipe.Name)
+	return fmt.Sprintf(`
+resource "rabbitmq_binding" "%s_mqtt" {
+  provider         = rabbitmq.%s
+  source           = "%s"
+  vhost            = %s
+  destination      = rabbitmq_queue.%s.name
+  destination_type = "queue"
+  routing_key      = "%s"
+}
+`, resourceName, rabbitMqProviderAlias(host), mqttExchange, rabbitMqVhostReference(host, pipe.Configs.VhostName(host.AdditionalProps["vhost"])), resourceName, strings.ReplaceAll(pipe.Name, "/", "."))
+}
+
+//returns the rabbitmq.conf settings of the MQTT plugin, terraform cannot enable plugins of the broker
+func createRabbitMqMqttConfig(host *models.Host) string {
+	port := host.AdditionalProps["mqtt_port"]
+	if port == "" {
+		port = defaultMqttPort
+	}
+	return fmt.Sprintf(`# generated by EICODA for host %s
+# enable the plugin with: rabbitmq-plugins enable rabbitmq_mqtt
+mqtt.listeners.tcp.default = %s
+mqtt.allow_anonymous = false
+mqtt.vhost = %s
+mqtt.exchange = %s
+`, host.Name, port, rabbitMqHostVhost(host), mqttExchange)
+}
+
+//returns the name of the terraform resource of a pipe. Characters other than letters, digits and _ are replaced and
+//a leading digit gets a _ in front, as terraform identifiers must not start with one
+func terraformResourceName(name string) string {
+	resourceName := invalidResourceChars.ReplaceAllString(name, "_")
+	if resourceName == "" || (resourceName[0] >= '0' && resourceName[0] <= '9') {
+		resourceName = "_" + resourceName
+	}
+	return resourceName
+}
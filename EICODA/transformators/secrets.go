@@ -0,0 +1,137 @@
+package transformators
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"eicoda/models"
+	"eicoda/utils"
+)
+
+//label marking the kubernetes secrets that hold the credentials of a deployment
+const SecretsLabel = "eicoda.secrets"
+
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+//returns the environment variable a credential of a host is passed with, e.g. EICODA_DEVRABBITMQ_PASSWORD
+func SecretEnvName(host *models.Host, prop string) string {
+	return invalidEnvChars.ReplaceAllString(strings.ToUpper("EICODA_"+host.Name+"_"+prop), "_")
+}
+
+//returns the identifiers the target models derive from the name of a pipe host: its credential variables, the
+//sanitized name of its secrets and files and the alias of its RabbitMQ provider. Distinct host names like dev-rabbit
+//and dev_rabbit can map to the same identifiers, so they have to be unique among the pipe hosts of a model
+func HostIdentifiers(host *models.Host) []string {
+	identifiers := []string{SecretEnvName(host, "") + "*", utils.SanitizeName(host.Name)}
+	if host.Type == "RabbitMQ" {
+		identifiers = append(identifiers, "rabbitmq."+rabbitMqProviderAlias(host))
+	}
+	return identifiers
+}
+
+//returns the credential properties a host sets, they are optional for Kafka hosts
+func hostCredentialProps(host *models.Host) []string {
+	var props []string
+	for _, prop := range models.CredentialProps {
+		if _, exists := host.AdditionalProps[prop]; exists {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+//returns the kubernetes secret and key holding a credential of a host. External secrets are referenced as
+//they are, all other credentials are stored in a secret per host that the kubernetes plugin creates
+func kubernetesSecretKeyRef(host *models.Host, prop string, options Options) (string, string) {
+	ref, _ := models.ParseSecretRef(host.AdditionalProps[prop])
+	if ref != nil && ref.Source == models.SecretSourceExternal {
+		return ref.Name, ref.Key
+	}
+	name := utils.SanitizeName(host.Name + "-credentials")
+	if options.DeploymentName != "" && options.DeploymentName != DefaultDeploymentName {
+		name = utils.SanitizeName(options.DeploymentName + "-" + name)
+	}
+	return name, prop
+}
+
+//returns the terraform variable a credential of a host is passed with
+func terraformVariableName(host *models.Host, prop string) string {
+	return strings.ToLower(SecretEnvName(host, prop))
+}
+
+//resolves the credentials of all pipe hosts of the model and the passwords of the filters of Mosquitto hosts. External secrets are not resolved, the target
+//platforms read them themselves. Secret references are only resolved here, so models can be generated without them.
+//credentials that cannot be resolved are reported together, the resolved ones are returned anyway
+func CollectSecrets(model *models.Model, options Options) ([]models.Secret, error) {
+	var secrets []models.Secret
+	var errs []error
+	for i := range model.Hosts.PipeHosts {
+		host := &model.Hosts.PipeHosts[i]
+		for _, prop := range hostCredentialProps(host) {
+			value := host.AdditionalProps[prop]
+			ref, err := models.ParseSecretRef(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("host %s: %w", host.Name, err))
+				continue
+			}
+
+			//recorded models do not contain plaintext credentials, e.g. when a deployment is destroyed
+			if value == models.RedactedValue {
+				envValue, exists := os.LookupEnv(SecretEnvName(host, prop))
+				if !exists {
+					errs = append(errs, fmt.Errorf("the %s of host %s is not recorded, set %s", prop, host.Name, SecretEnvName(host, prop)))
+					continue
+				}
+				value = envValue
+			}
+
+			if ref != nil {
+				switch ref.Source {
+				case models.SecretSourceExternal:
+					continue
+				case models.SecretSourceEnv:
+					envValue, exists := os.LookupEnv(ref.Name)
+					if !exists {
+						errs = append(errs, fmt.Errorf("environment variable %s for the %s of host %s is not set", ref.Name, prop, host.Name))
+						continue
+					}
+					value = envValue
+				case models.SecretSourceFile:
+					content, err := os.ReadFile(filepath.Join(options.BaseDir, ref.Name))
+					if err != nil {
+						errs = append(errs, fmt.Errorf("failed to read the %s of host %s: %w", prop, host.Name, err))
+						continue
+					}
+					value = strings.TrimRight(string(content), "\r\n")
+				}
+			}
+
+			secretName, key := kubernetesSecretKeyRef(host, prop, options)
+			secrets = append(secrets, models.Secret{
+				EnvName:    SecretEnvName(host, prop),
+				SecretName: secretName,
+				Key:        key,
+				Value:      value,
+			})
+
+			//every filter of a Mosquitto host gets its own password, derived from the one of the host
+			if host.Type == "Mosquitto" && prop == "password" {
+				for _, filter := range mosquittoFilters(model, host) {
+					filterProp := mosquittoPasswordProp(MosquittoUser(filter))
+					secretName, key := kubernetesSecretKeyRef(host, filterProp, options)
+					secrets = append(secrets, models.Secret{
+						EnvName:    SecretEnvName(host, filterProp),
+						SecretName: secretName,
+						Key:        key,
+						Value:      mosquittoPassword(value, MosquittoUser(filter)),
+					})
+				}
+			}
+		}
+	}
+	return secrets, errors.Join(errs...)
+}
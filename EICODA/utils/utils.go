@@ -1,7 +1,13 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"eicoda/models"
@@ -76,3 +82,79 @@ func ConvertToProperType(value string) string {
 	}
 	return value
 }
+
+//renders a config value for the environment of a filter, typed values are normalised and untyped ones are converted by guessing their type
+func RenderConfigValue(config models.FilterConfig, value string) string {
+	if config.Type == "" {
+		return ConvertToProperType(value)
+	}
+	normalized, err := config.NormalizeValue(value)
+	if err != nil {
+		return value
+	}
+	return normalized
+}
+
+//returns the hex encoded sha256 hash of the given content
+func HashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//hashes the contents of all file backed configs of a filter so that changes of those files can be detected, returns an empty string if the filter has none
+func HashFileConfigs(filterType *models.FilterType, filter models.Filter, baseDir string) string {
+	if filterType == nil {
+		return ""
+	}
+
+	var content []byte
+	hasFiles := false
+	for _, config := range filterType.Configs {
+		if !config.IsFile() {
+			continue
+		}
+		hasFiles = true
+		value, exists := filter.AdditionalProps[config.Name]
+		if !exists {
+			value = fmt.Sprintf("%v", config.Default)
+		}
+		fileContent, err := os.ReadFile(filepath.Join(baseDir, value))
+		if err != nil {
+			continue
+		}
+		content = append(content, []byte(config.Name+"\n")...)
+		content = append(content, fileContent...)
+	}
+
+	if !hasFiles {
+		return ""
+	}
+	return HashContent(content)
+}
+
+//returns a copy of the filters sorted by name so that generated models have a stable order
+func SortFiltersByName(filters []models.Filter) []models.Filter {
+	sorted := append([]models.Filter(nil), filters...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+//returns a copy of the queues sorted by name so that generated models have a stable order
+func SortQueuesByName(queues []models.Queue) []models.Queue {
+	sorted := append([]models.Queue(nil), queues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+//returns a copy of the topics sorted by name so that generated models have a stable order
+func SortTopicsByName(topics []models.Topic) []models.Topic {
+	sorted := append([]models.Topic(nil), topics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}